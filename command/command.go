@@ -5,6 +5,7 @@ import (
 	"fmt"
 	"kirjasto/command/version"
 	"kirjasto/config"
+	"kirjasto/goes"
 	"kirjasto/tracing"
 	"os"
 	"os/signal"
@@ -24,12 +25,25 @@ type CommandDefinition interface {
 
 func NewCommand(definition CommandDefinition) func() (cli.Command, error) {
 	return func() (cli.Command, error) {
-		return &command{definition}, nil
+		return &command{CommandDefinition: definition}, nil
+	}
+}
+
+// Named records the name a command is registered under, which is added to the
+// metadata of any events the command saves.
+func Named(name string, factory cli.CommandFactory) cli.CommandFactory {
+	return func() (cli.Command, error) {
+		c, err := factory()
+		if cmd, ok := c.(*command); ok {
+			cmd.name = name
+		}
+		return c, err
 	}
 }
 
 type command struct {
 	CommandDefinition
+	name string
 }
 
 func (c *command) Help() string {
@@ -65,6 +79,9 @@ func (c *command) Run(args []string) int {
 	ctx, span := tr.Start(ctx, "main")
 	defer span.End()
 
+	ctx = goes.WithCommand(ctx, c.name)
+	ctx = goes.WithActor(ctx, cfg.Actor)
+
 	flags := c.Flags()
 
 	if err := flags.Parse(args); err != nil {
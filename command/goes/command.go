@@ -2,11 +2,14 @@ package goes
 
 import (
 	"context"
+	"fmt"
 	"kirjasto/config"
 	"kirjasto/domain"
 	"kirjasto/goes"
 	"kirjasto/storage"
 	"kirjasto/tracing"
+	"slices"
+	"strings"
 
 	"github.com/spf13/pflag"
 	"go.opentelemetry.io/otel"
@@ -19,14 +22,16 @@ func NewGoesCommand() *GoesProjectionCommand {
 }
 
 type GoesProjectionCommand struct {
+	dryRun bool
 }
 
 func (c *GoesProjectionCommand) Synopsis() string {
-	return "rerun the projections"
+	return "rerun the named projections, or all of them if no names are given"
 }
 
 func (c *GoesProjectionCommand) Flags() *pflag.FlagSet {
-	flags := pflag.NewFlagSet("server", pflag.ContinueOnError)
+	flags := pflag.NewFlagSet("rebuild", pflag.ContinueOnError)
+	flags.BoolVar(&c.dryRun, "dry-run", false, "report what each projection would process, without rebuilding")
 	return flags
 }
 
@@ -39,19 +44,63 @@ func (c *GoesProjectionCommand) Execute(ctx context.Context, config *config.Conf
 		return tracing.Error(span, err)
 	}
 
-	eventStore := goes.NewSqliteStore(db)
-	if err := eventStore.Initialise(ctx); err != nil {
+	// the projections are rebuilt below, so there's no need to check their versions first
+	eventStore := goes.NewSqliteStore(db, goes.WithStalePolicy(goes.IgnoreStale))
+	if err := domain.RegisterProjections(eventStore); err != nil {
 		return tracing.Error(span, err)
 	}
 
-	projection := domain.NewLibraryProjection()
-	if err := eventStore.RegisterProjection("library_view", projection); err != nil {
+	if err := eventStore.Initialise(ctx); err != nil {
 		return tracing.Error(span, err)
 	}
 
-	if err := eventStore.Rebuild(ctx, projection.SqlProjection); err != nil {
+	projections, err := selectProjections(eventStore.Projections(), args)
+	if err != nil {
 		return tracing.Error(span, err)
 	}
 
+	for _, projection := range projections {
+		if c.dryRun {
+			stats, err := goes.DryRunRebuild(ctx, eventStore, projection.Projection)
+			if err != nil {
+				return tracing.Error(span, err)
+			}
+
+			fmt.Printf("%s: %d events, %d aggregates\n", projection.Name, stats.Events, stats.Aggregates)
+			continue
+		}
+
+		fmt.Println("Rebuilding", projection.Name)
+		if err := eventStore.Rebuild(ctx, projection.Projection); err != nil {
+			return tracing.Error(span, err)
+		}
+	}
+
 	return nil
 }
+
+func selectProjections(registered []goes.RegisteredProjection, names []string) ([]goes.RegisteredProjection, error) {
+	if len(names) == 0 {
+		return registered, nil
+	}
+
+	selected := make([]goes.RegisteredProjection, 0, len(names))
+	for _, name := range names {
+		index := slices.IndexFunc(registered, func(p goes.RegisteredProjection) bool { return p.Name == name })
+		if index == -1 {
+			return nil, fmt.Errorf("no projection called '%s' found, available projections: %s", name, projectionNames(registered))
+		}
+
+		selected = append(selected, registered[index])
+	}
+
+	return selected, nil
+}
+
+func projectionNames(registered []goes.RegisteredProjection) string {
+	names := make([]string, len(registered))
+	for i, p := range registered {
+		names[i] = p.Name
+	}
+	return strings.Join(names, ", ")
+}
@@ -0,0 +1,65 @@
+package goes
+
+import (
+	"context"
+	"fmt"
+	"kirjasto/config"
+	"kirjasto/goes"
+	"kirjasto/storage"
+	"kirjasto/tracing"
+	"kirjasto/util/columnize"
+	"time"
+
+	"github.com/spf13/pflag"
+)
+
+func NewDeliveriesCommand() *DeliveriesCommand {
+	return &DeliveriesCommand{}
+}
+
+type DeliveriesCommand struct {
+	limit int
+}
+
+func (c *DeliveriesCommand) Synopsis() string {
+	return "show the delivery log of webhook messages sent from the outbox"
+}
+
+func (c *DeliveriesCommand) Flags() *pflag.FlagSet {
+	flags := pflag.NewFlagSet("deliveries", pflag.ContinueOnError)
+	flags.IntVar(&c.limit, "limit", 20, "how many of the most recent attempts to show")
+	return flags
+}
+
+func (c *DeliveriesCommand) Execute(ctx context.Context, config *config.Config, args []string) error {
+	ctx, span := tr.Start(ctx, "execute")
+	defer span.End()
+
+	db, err := storage.Reader(ctx, config.DatabaseFile)
+	if err != nil {
+		return tracing.Error(span, err)
+	}
+
+	eventStore := goes.NewSqliteStore(db)
+	if err := eventStore.Initialise(ctx); err != nil {
+		return tracing.Error(span, err)
+	}
+
+	attempts, err := eventStore.DeliveryLog(ctx, c.limit)
+	if err != nil {
+		return tracing.Error(span, err)
+	}
+
+	rows := []string{"delivery_id | event_id | destination | attempted_at | result"}
+	for _, a := range attempts {
+		result := "failed: " + a.Detail
+		if a.Succeeded {
+			result = a.Detail
+		}
+		rows = append(rows, fmt.Sprintf("%d | %d | %s | %s | %s", a.MessageID, a.Position, a.Destination, a.AttemptedAt.Format(time.DateTime), result))
+	}
+
+	fmt.Println(columnize.SimpleFormat(rows))
+
+	return nil
+}
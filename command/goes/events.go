@@ -0,0 +1,367 @@
+package goes
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"kirjasto/config"
+	"kirjasto/domain"
+	"kirjasto/goes"
+	"kirjasto/storage"
+	"kirjasto/tracing"
+	"kirjasto/util/columnize"
+	"maps"
+	"os"
+	"slices"
+	"strconv"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/spf13/pflag"
+)
+
+// eventFlags are the filters shared by the events list and tail commands
+type eventFlags struct {
+	aggregateIDs   []string
+	aggregateTypes []string
+	eventTypes     []string
+	since          string
+	until          string
+	minSequence    int
+	maxSequence    int
+	json           bool
+}
+
+func (f *eventFlags) register(flags *pflag.FlagSet) {
+	flags.StringSliceVar(&f.aggregateIDs, "aggregate", []string{}, "only show events for these aggregate ids")
+	flags.StringSliceVar(&f.aggregateTypes, "aggregate-type", []string{}, "only show events for aggregates of these types")
+	flags.StringSliceVar(&f.eventTypes, "type", []string{}, "only show events of these types")
+	flags.StringVar(&f.since, "since", "", "only show events saved on or after this date or time")
+	flags.StringVar(&f.until, "until", "", "only show events saved on or before this date or time")
+	flags.IntVar(&f.minSequence, "min-sequence", -1, "only show events with at least this sequence")
+	flags.IntVar(&f.maxSequence, "max-sequence", -1, "only show events with at most this sequence")
+	flags.BoolVar(&f.json, "json", false, "write each event as a line of json")
+}
+
+func (f *eventFlags) filter() (goes.EventFilter, error) {
+	filter := goes.EventFilter{
+		EventTypes:     f.eventTypes,
+		AggregateTypes: f.aggregateTypes,
+	}
+
+	for _, id := range f.aggregateIDs {
+		aggregateID, err := uuid.Parse(id)
+		if err != nil {
+			return filter, err
+		}
+		filter.AggregateIDs = append(filter.AggregateIDs, aggregateID)
+	}
+
+	var err error
+	if filter.Since, _, err = parseTime(f.since); err != nil {
+		return filter, err
+	}
+
+	// a date on its own includes the whole of that day
+	until, dateOnly, err := parseTime(f.until)
+	if err != nil {
+		return filter, err
+	}
+	if dateOnly {
+		until = until.Add(24*time.Hour - time.Nanosecond)
+	}
+	filter.Until = until
+
+	if f.minSequence >= 0 {
+		filter.MinSequence = &f.minSequence
+	}
+	if f.maxSequence >= 0 {
+		filter.MaxSequence = &f.maxSequence
+	}
+
+	return filter, nil
+}
+
+// parseTime accepts either a date or an RFC3339 timestamp, with an empty value being the
+// zero time.  The bool is true when the value was just a date.
+func parseTime(value string) (time.Time, bool, error) {
+	if value == "" {
+		return time.Time{}, false, nil
+	}
+
+	if t, err := time.Parse(time.DateOnly, value); err == nil {
+		return t, true, nil
+	}
+
+	t, err := time.Parse(time.RFC3339, value)
+	return t, false, err
+}
+
+func openEventStore(ctx context.Context, config *config.Config) (*goes.SqliteStore, error) {
+	db, err := storage.Reader(ctx, config.DatabaseFile)
+	if err != nil {
+		return nil, err
+	}
+
+	domain.RegisterEvents()
+	eventStore := goes.NewSqliteStore(db)
+
+	// brings the events table up to date, as reading events relies on its newer columns
+	if err := eventStore.Initialise(ctx); err != nil {
+		return nil, err
+	}
+
+	return eventStore, nil
+}
+
+type eventOutput struct {
+	EventID int64 `json:"event_id"`
+	goes.ExportedEvent
+}
+
+func newEventOutput(event goes.EventDescriptor) (eventOutput, error) {
+	data, err := event.Marshal()
+	if err != nil {
+		return eventOutput{}, err
+	}
+
+	return eventOutput{
+		EventID: event.Position,
+		ExportedEvent: goes.ExportedEvent{
+			AggregateID:   event.AggregateID,
+			AggregateType: event.AggregateType,
+			Sequence:      event.Sequence,
+			Timestamp:     event.Timestamp,
+			EventType:     event.EventType,
+			Version:       event.Version,
+			Data:          data,
+			Metadata:      event.Metadata,
+		},
+	}, nil
+}
+
+func eventRow(event goes.EventDescriptor) string {
+	return fmt.Sprintf("%d | %s | %s | %d | %s | %s",
+		event.Position,
+		event.AggregateType,
+		event.AggregateID,
+		event.Sequence,
+		event.Timestamp.Format(time.DateTime),
+		event.EventType,
+	)
+}
+
+const eventHeader = "event_id | aggregate_type | aggregate_id | sequence | timestamp | event_type"
+
+func NewEventsListCommand() *EventsListCommand {
+	return &EventsListCommand{}
+}
+
+type EventsListCommand struct {
+	flags eventFlags
+}
+
+func (c *EventsListCommand) Synopsis() string {
+	return "list the events in the store"
+}
+
+func (c *EventsListCommand) Flags() *pflag.FlagSet {
+	flags := pflag.NewFlagSet("list", pflag.ContinueOnError)
+	c.flags.register(flags)
+	return flags
+}
+
+func (c *EventsListCommand) Execute(ctx context.Context, config *config.Config, args []string) error {
+	ctx, span := tr.Start(ctx, "execute")
+	defer span.End()
+
+	filter, err := c.flags.filter()
+	if err != nil {
+		return tracing.Error(span, err)
+	}
+
+	eventStore, err := openEventStore(ctx, config)
+	if err != nil {
+		return tracing.Error(span, err)
+	}
+
+	encoder := json.NewEncoder(os.Stdout)
+	rows := []string{eventHeader}
+
+	for event, err := range eventStore.Events(ctx, filter) {
+		if err != nil {
+			return tracing.Error(span, err)
+		}
+
+		if !c.flags.json {
+			rows = append(rows, eventRow(event))
+			continue
+		}
+
+		output, err := newEventOutput(event)
+		if err != nil {
+			return tracing.Error(span, err)
+		}
+		if err := encoder.Encode(output); err != nil {
+			return tracing.Error(span, err)
+		}
+	}
+
+	if !c.flags.json {
+		fmt.Println(columnize.SimpleFormat(rows))
+	}
+
+	return nil
+}
+
+func NewEventsShowCommand() *EventsShowCommand {
+	return &EventsShowCommand{}
+}
+
+type EventsShowCommand struct {
+	json bool
+}
+
+func (c *EventsShowCommand) Synopsis() string {
+	return "show a single event, by its event_id"
+}
+
+func (c *EventsShowCommand) Flags() *pflag.FlagSet {
+	flags := pflag.NewFlagSet("show", pflag.ContinueOnError)
+	flags.BoolVar(&c.json, "json", false, "write the event as json")
+	return flags
+}
+
+func (c *EventsShowCommand) Execute(ctx context.Context, config *config.Config, args []string) error {
+	ctx, span := tr.Start(ctx, "execute")
+	defer span.End()
+
+	if len(args) != 1 {
+		return tracing.Errorf(span, "expected exactly one event_id, got %d", len(args))
+	}
+
+	position, err := strconv.ParseInt(args[0], 10, 64)
+	if err != nil {
+		return tracing.Error(span, err)
+	}
+
+	eventStore, err := openEventStore(ctx, config)
+	if err != nil {
+		return tracing.Error(span, err)
+	}
+
+	event, err := eventStore.Event(ctx, position)
+	if err != nil {
+		return tracing.Error(span, err)
+	}
+
+	if c.json {
+		output, err := newEventOutput(event)
+		if err != nil {
+			return tracing.Error(span, err)
+		}
+
+		content, err := json.MarshalIndent(output, "", "  ")
+		if err != nil {
+			return tracing.Error(span, err)
+		}
+
+		fmt.Println(string(content))
+		return nil
+	}
+
+	rows := []string{
+		fmt.Sprintf("event_id: | %d", event.Position),
+		fmt.Sprintf("aggregate_id: | %s", event.AggregateID),
+		fmt.Sprintf("sequence: | %d", event.Sequence),
+		fmt.Sprintf("timestamp: | %s", event.Timestamp.Format(time.RFC3339)),
+		fmt.Sprintf("event_type: | %s (version %d)", event.EventType, event.Version),
+	}
+	if event.Hash != "" {
+		rows = append(rows, fmt.Sprintf("hash: | %s", event.Hash))
+	}
+	for _, key := range slices.Sorted(maps.Keys(event.Metadata)) {
+		rows = append(rows, fmt.Sprintf("%s: | %s", key, event.Metadata[key]))
+	}
+	fmt.Println(columnize.SimpleFormat(rows))
+
+	payload, err := json.MarshalIndent(event.Event, "", "  ")
+	if err != nil {
+		return tracing.Error(span, err)
+	}
+
+	fmt.Println()
+	fmt.Println(string(payload))
+
+	return nil
+}
+
+func NewEventsTailCommand() *EventsTailCommand {
+	return &EventsTailCommand{}
+}
+
+type EventsTailCommand struct {
+	flags eventFlags
+	from  int64
+}
+
+func (c *EventsTailCommand) Synopsis() string {
+	return "follow new events as they are saved"
+}
+
+func (c *EventsTailCommand) Flags() *pflag.FlagSet {
+	flags := pflag.NewFlagSet("tail", pflag.ContinueOnError)
+	c.flags.register(flags)
+	flags.Int64Var(&c.from, "from", -1, "start after this event_id, rather than the newest event")
+	return flags
+}
+
+func (c *EventsTailCommand) Execute(ctx context.Context, config *config.Config, args []string) error {
+	ctx, span := tr.Start(ctx, "execute")
+	defer span.End()
+
+	filter, err := c.flags.filter()
+	if err != nil {
+		return tracing.Error(span, err)
+	}
+
+	eventStore, err := openEventStore(ctx, config)
+	if err != nil {
+		return tracing.Error(span, err)
+	}
+
+	from := c.from
+	if from < 0 {
+		if from, err = eventStore.LastPosition(ctx); err != nil {
+			return tracing.Error(span, err)
+		}
+	}
+
+	encoder := json.NewEncoder(os.Stdout)
+	if !c.flags.json {
+		fmt.Println(columnize.SimpleFormat([]string{eventHeader}))
+	}
+
+	// the subscription ends when the command is interrupted
+	for event, err := range eventStore.Subscribe(ctx, from, filter) {
+		if err != nil {
+			return tracing.Error(span, err)
+		}
+
+		if !c.flags.json {
+			// events arrive one at a time, so rows can't be aligned with the header
+			fmt.Println(columnize.SimpleFormat([]string{eventRow(event)}))
+			continue
+		}
+
+		output, err := newEventOutput(event)
+		if err != nil {
+			return tracing.Error(span, err)
+		}
+		if err := encoder.Encode(output); err != nil {
+			return tracing.Error(span, err)
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,65 @@
+package goes
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"kirjasto/config"
+	"kirjasto/goes"
+	"kirjasto/tracing"
+	"os"
+
+	"github.com/spf13/pflag"
+)
+
+func NewExportCommand() *ExportCommand {
+	return &ExportCommand{}
+}
+
+type ExportCommand struct {
+}
+
+func (c *ExportCommand) Synopsis() string {
+	return "write every event to a jsonl file, or stdout if no file is given"
+}
+
+func (c *ExportCommand) Flags() *pflag.FlagSet {
+	flags := pflag.NewFlagSet("export", pflag.ContinueOnError)
+	return flags
+}
+
+func (c *ExportCommand) Execute(ctx context.Context, config *config.Config, args []string) error {
+	ctx, span := tr.Start(ctx, "execute")
+	defer span.End()
+
+	if len(args) > 1 {
+		return tracing.Errorf(span, "expected at most one file, got %d", len(args))
+	}
+
+	eventStore, err := openEventStore(ctx, config)
+	if err != nil {
+		return tracing.Error(span, err)
+	}
+
+	var output io.Writer = os.Stdout
+	if len(args) == 1 && args[0] != "-" {
+		file, err := os.Create(args[0])
+		if err != nil {
+			return tracing.Error(span, err)
+		}
+		defer file.Close()
+
+		output = file
+	}
+
+	count, err := goes.Export(ctx, eventStore, output)
+	if err != nil {
+		return tracing.Error(span, err)
+	}
+
+	if output != os.Stdout {
+		fmt.Printf("Exported %d events\n", count)
+	}
+
+	return nil
+}
@@ -0,0 +1,125 @@
+package goes
+
+import (
+	"context"
+	"fmt"
+	"kirjasto/config"
+	"kirjasto/domain"
+	"kirjasto/goes"
+	"kirjasto/storage"
+	"kirjasto/tracing"
+	"kirjasto/util/columnize"
+	"slices"
+	"time"
+
+	"github.com/spf13/pflag"
+)
+
+func openFailuresStore(ctx context.Context, config *config.Config) (*goes.SqliteStore, error) {
+	db, err := storage.Writer(ctx, config.DatabaseFile)
+	if err != nil {
+		return nil, err
+	}
+
+	eventStore := goes.NewSqliteStore(db)
+	if err := domain.RegisterProjections(eventStore); err != nil {
+		return nil, err
+	}
+
+	if err := eventStore.Initialise(ctx); err != nil {
+		return nil, err
+	}
+
+	return eventStore, nil
+}
+
+func NewFailuresCommand() *FailuresCommand {
+	return &FailuresCommand{}
+}
+
+type FailuresCommand struct {
+}
+
+func (c *FailuresCommand) Synopsis() string {
+	return "list the events which isolated projections failed to process"
+}
+
+func (c *FailuresCommand) Flags() *pflag.FlagSet {
+	flags := pflag.NewFlagSet("failures", pflag.ContinueOnError)
+	return flags
+}
+
+func (c *FailuresCommand) Execute(ctx context.Context, config *config.Config, args []string) error {
+	ctx, span := tr.Start(ctx, "execute")
+	defer span.End()
+
+	eventStore, err := openFailuresStore(ctx, config)
+	if err != nil {
+		return tracing.Error(span, err)
+	}
+
+	failures, err := eventStore.Failures(ctx)
+	if err != nil {
+		return tracing.Error(span, err)
+	}
+
+	rows := []string{"projection | event_id | event_type | failed_at | error"}
+	for _, f := range failures {
+		rows = append(rows, fmt.Sprintf("%s | %d | %s | %s | %s", f.Projection, f.Position, f.EventType, f.FailedAt.Format(time.DateTime), f.Error))
+	}
+
+	fmt.Println(columnize.SimpleFormat(rows))
+
+	return nil
+}
+
+func NewRetryFailuresCommand() *RetryFailuresCommand {
+	return &RetryFailuresCommand{}
+}
+
+type RetryFailuresCommand struct {
+}
+
+func (c *RetryFailuresCommand) Synopsis() string {
+	return "project the failed events again for the named projections, or all faulted projections if no names are given"
+}
+
+func (c *RetryFailuresCommand) Flags() *pflag.FlagSet {
+	flags := pflag.NewFlagSet("retry", pflag.ContinueOnError)
+	return flags
+}
+
+func (c *RetryFailuresCommand) Execute(ctx context.Context, config *config.Config, args []string) error {
+	ctx, span := tr.Start(ctx, "execute")
+	defer span.End()
+
+	eventStore, err := openFailuresStore(ctx, config)
+	if err != nil {
+		return tracing.Error(span, err)
+	}
+
+	names := args
+	if len(names) == 0 {
+		failures, err := eventStore.Failures(ctx)
+		if err != nil {
+			return tracing.Error(span, err)
+		}
+
+		for _, f := range failures {
+			if !slices.Contains(names, f.Projection) {
+				names = append(names, f.Projection)
+			}
+		}
+	}
+
+	for _, name := range names {
+		count, err := eventStore.RetryFailures(ctx, name)
+		if err != nil {
+			return tracing.Error(span, fmt.Errorf("retrying %s: %w", name, err))
+		}
+
+		fmt.Printf("%s: projected %d events\n", name, count)
+	}
+
+	return nil
+}
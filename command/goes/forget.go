@@ -0,0 +1,68 @@
+package goes
+
+import (
+	"context"
+	"fmt"
+	"kirjasto/config"
+	"kirjasto/domain"
+	"kirjasto/goes"
+	"kirjasto/storage"
+	"kirjasto/tracing"
+
+	"github.com/spf13/pflag"
+)
+
+func NewForgetCommand() *ForgetCommand {
+	return &ForgetCommand{}
+}
+
+type ForgetCommand struct {
+}
+
+func (c *ForgetCommand) Synopsis() string {
+	return "delete a subject's encryption key, redacting their personal data, and rebuild the views"
+}
+
+func (c *ForgetCommand) Flags() *pflag.FlagSet {
+	flags := pflag.NewFlagSet("forget", pflag.ContinueOnError)
+	return flags
+}
+
+func (c *ForgetCommand) Execute(ctx context.Context, config *config.Config, args []string) error {
+	ctx, span := tr.Start(ctx, "execute")
+	defer span.End()
+
+	if len(args) != 1 {
+		return tracing.Errorf(span, "expected exactly one subject, got %d", len(args))
+	}
+
+	db, err := storage.Writer(ctx, config.DatabaseFile)
+	if err != nil {
+		return tracing.Error(span, err)
+	}
+
+	domain.RegisterEvents()
+
+	eventStore := goes.NewSqliteStore(db, config.StoreOptions()...)
+	if err := domain.RegisterProjections(eventStore); err != nil {
+		return tracing.Error(span, err)
+	}
+
+	if err := eventStore.Initialise(ctx); err != nil {
+		return tracing.Error(span, err)
+	}
+
+	if err := eventStore.ForgetSubject(ctx, args[0]); err != nil {
+		return tracing.Error(span, err)
+	}
+
+	// the views still hold the decrypted values until they are rebuilt
+	for _, projection := range eventStore.Projections() {
+		fmt.Println("Rebuilding", projection.Name)
+		if err := eventStore.Rebuild(ctx, projection.Projection); err != nil {
+			return tracing.Error(span, err)
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,70 @@
+package goes
+
+import (
+	"context"
+	"fmt"
+	"kirjasto/config"
+	"kirjasto/domain"
+	"kirjasto/goes"
+	"kirjasto/storage"
+	"kirjasto/tracing"
+	"os"
+
+	"github.com/spf13/pflag"
+)
+
+func NewImportCommand() *ImportCommand {
+	return &ImportCommand{}
+}
+
+type ImportCommand struct {
+}
+
+func (c *ImportCommand) Synopsis() string {
+	return "save the events from a jsonl file written by goes export"
+}
+
+func (c *ImportCommand) Flags() *pflag.FlagSet {
+	flags := pflag.NewFlagSet("import", pflag.ContinueOnError)
+	return flags
+}
+
+func (c *ImportCommand) Execute(ctx context.Context, config *config.Config, args []string) error {
+	ctx, span := tr.Start(ctx, "execute")
+	defer span.End()
+
+	if len(args) != 1 {
+		return tracing.Errorf(span, "expected exactly one file, got %d", len(args))
+	}
+
+	file, err := os.Open(args[0])
+	if err != nil {
+		return tracing.Error(span, err)
+	}
+	defer file.Close()
+
+	db, err := storage.Writer(ctx, config.DatabaseFile)
+	if err != nil {
+		return tracing.Error(span, err)
+	}
+
+	domain.RegisterEvents()
+
+	eventStore := goes.NewSqliteStore(db, config.StoreOptions()...)
+	if err := domain.RegisterProjections(eventStore); err != nil {
+		return tracing.Error(span, err)
+	}
+
+	if err := eventStore.Initialise(ctx); err != nil {
+		return tracing.Error(span, err)
+	}
+
+	count, err := goes.Import(ctx, eventStore, file)
+	if err != nil {
+		return tracing.Error(span, err)
+	}
+
+	fmt.Printf("Imported %d events\n", count)
+
+	return nil
+}
@@ -0,0 +1,71 @@
+package goes
+
+import (
+	"context"
+	"fmt"
+	"kirjasto/config"
+	"kirjasto/domain"
+	"kirjasto/goes"
+	"kirjasto/storage"
+	"kirjasto/tracing"
+	"time"
+
+	"github.com/spf13/pflag"
+)
+
+func NewProjectCommand() *ProjectCommand {
+	return &ProjectCommand{}
+}
+
+type ProjectCommand struct {
+	follow    bool
+	batchSize int
+	interval  time.Duration
+}
+
+func (c *ProjectCommand) Synopsis() string {
+	return "catch up the async projections"
+}
+
+func (c *ProjectCommand) Flags() *pflag.FlagSet {
+	flags := pflag.NewFlagSet("project", pflag.ContinueOnError)
+	flags.BoolVar(&c.follow, "follow", false, "keep running, projecting new events as they are saved")
+	flags.IntVar(&c.batchSize, "batch-size", goes.DefaultBatchSize, "how many events to project in each transaction")
+	flags.DurationVar(&c.interval, "interval", time.Second, "how often to check for new events when following")
+	return flags
+}
+
+func (c *ProjectCommand) Execute(ctx context.Context, config *config.Config, args []string) error {
+	ctx, span := tr.Start(ctx, "execute")
+	defer span.End()
+
+	db, err := storage.Writer(ctx, config.DatabaseFile)
+	if err != nil {
+		return tracing.Error(span, err)
+	}
+
+	eventStore := goes.NewSqliteStore(db)
+	if err := domain.RegisterProjections(eventStore); err != nil {
+		return tracing.Error(span, err)
+	}
+
+	if err := eventStore.Initialise(ctx); err != nil {
+		return tracing.Error(span, err)
+	}
+
+	if c.follow {
+		if err := eventStore.RunProjector(ctx, c.batchSize, c.interval); err != nil {
+			return tracing.Error(span, err)
+		}
+		return nil
+	}
+
+	count, err := eventStore.CatchUp(ctx, c.batchSize)
+	if err != nil {
+		return tracing.Error(span, err)
+	}
+
+	fmt.Printf("Projected %d events\n", count)
+
+	return nil
+}
@@ -0,0 +1,79 @@
+package goes
+
+import (
+	"context"
+	"fmt"
+	"kirjasto/config"
+	"kirjasto/domain"
+	"kirjasto/goes"
+	"kirjasto/storage"
+	"kirjasto/tracing"
+	"kirjasto/util/columnize"
+
+	"github.com/spf13/pflag"
+)
+
+func NewProjectionsCommand() *ProjectionsCommand {
+	return &ProjectionsCommand{}
+}
+
+type ProjectionsCommand struct {
+}
+
+func (c *ProjectionsCommand) Synopsis() string {
+	return "list the registered projections"
+}
+
+func (c *ProjectionsCommand) Flags() *pflag.FlagSet {
+	flags := pflag.NewFlagSet("projections", pflag.ContinueOnError)
+	return flags
+}
+
+func (c *ProjectionsCommand) Execute(ctx context.Context, config *config.Config, args []string) error {
+	ctx, span := tr.Start(ctx, "execute")
+	defer span.End()
+
+	db, err := storage.Reader(ctx, config.DatabaseFile)
+	if err != nil {
+		return tracing.Error(span, err)
+	}
+
+	// listing shouldn't rebuild anything, it only needs the store's tables to exist
+	eventStore := goes.NewSqliteStore(db, goes.WithStalePolicy(goes.IgnoreStale))
+	if err := domain.RegisterProjections(eventStore); err != nil {
+		return tracing.Error(span, err)
+	}
+
+	if err := eventStore.Initialise(ctx); err != nil {
+		return tracing.Error(span, err)
+	}
+
+	failures, err := eventStore.Failures(ctx)
+	if err != nil {
+		return tracing.Error(span, err)
+	}
+
+	failed := map[string]int{}
+	for _, f := range failures {
+		failed[f.Projection]++
+	}
+
+	rows := []string{"name | mode | failures"}
+	for _, projection := range eventStore.Projections() {
+		rows = append(rows, fmt.Sprintf("%s | %s | %d", projection.Name, projectionMode(projection), failed[projection.Name]))
+	}
+
+	fmt.Println(columnize.SimpleFormat(rows))
+
+	return nil
+}
+
+func projectionMode(projection goes.RegisteredProjection) string {
+	if projection.Async {
+		return "async"
+	}
+	if projection.Isolated {
+		return "inline, isolated"
+	}
+	return "inline"
+}
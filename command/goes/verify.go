@@ -0,0 +1,56 @@
+package goes
+
+import (
+	"context"
+	"fmt"
+	"kirjasto/config"
+	"kirjasto/tracing"
+
+	"github.com/spf13/pflag"
+)
+
+func NewVerifyCommand() *VerifyCommand {
+	return &VerifyCommand{}
+}
+
+type VerifyCommand struct {
+}
+
+func (c *VerifyCommand) Synopsis() string {
+	return "check the event log's hash chain, and for gaps in aggregate sequences"
+}
+
+func (c *VerifyCommand) Flags() *pflag.FlagSet {
+	flags := pflag.NewFlagSet("verify", pflag.ContinueOnError)
+	return flags
+}
+
+func (c *VerifyCommand) Execute(ctx context.Context, config *config.Config, args []string) error {
+	ctx, span := tr.Start(ctx, "execute")
+	defer span.End()
+
+	eventStore, err := openEventStore(ctx, config)
+	if err != nil {
+		return tracing.Error(span, err)
+	}
+
+	report, err := eventStore.Verify(ctx)
+	if err != nil {
+		return tracing.Error(span, err)
+	}
+
+	fmt.Printf("Checked %d events, %d of which are hash chained\n", report.Events, report.Chained)
+
+	if report.BrokenLink != nil {
+		fmt.Println("Broken link:", report.BrokenLink)
+	}
+	for _, gap := range report.Gaps {
+		fmt.Println("Sequence gap:", gap)
+	}
+
+	if !report.Ok() {
+		return tracing.Errorf(span, "the event log has been modified")
+	}
+
+	return nil
+}
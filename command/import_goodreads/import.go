@@ -2,7 +2,9 @@ package import_goodreads
 
 import (
 	"context"
+	"crypto/sha256"
 	"encoding/csv"
+	"encoding/hex"
 	"encoding/json"
 	"fmt"
 	"io"
@@ -55,28 +57,31 @@ func (c *ImportCommand) Execute(ctx context.Context, config *config.Config, args
 		return tracing.Error(span, err)
 	}
 
-	eventStore := goes.NewSqliteStore(db)
-	if err := eventStore.Initialise(ctx); err != nil {
+	eventStore := goes.NewSqliteStore(db, config.StoreOptions()...)
+	if err := domain.RegisterProjections(eventStore); err != nil {
 		return tracing.Error(span, err)
 	}
 
-	if err := eventStore.RegisterProjection("library_view", domain.NewLibraryProjection()); err != nil {
+	if err := eventStore.Initialise(ctx); err != nil {
 		return tracing.Error(span, err)
 	}
 
-	library, err := domain.LoadLibrary(ctx, eventStore, domain.LibraryID)
+	rows, err := processFile(ctx, filePath)
 	if err != nil {
-		if err != goes.ErrNotFound {
-			return tracing.Error(span, err)
-		}
-		library = domain.NewLibrary(domain.LibraryID)
-	}
-
-	if err := processFile(ctx, library, filePath); err != nil {
 		return tracing.Error(span, err)
 	}
 
-	if err := domain.SaveLibrary(ctx, eventStore, library); err != nil {
+	// the rows are imported together, so a failure imports nothing, and running the
+	// import again skips the rows which have already been imported
+	err = domain.UpdateLibrary(ctx, eventStore, domain.LibraryID, func(library *domain.Library) error {
+		for _, row := range rows {
+			if err := library.ImportBook(row); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
 		return tracing.Error(span, err)
 	}
 
@@ -132,18 +137,29 @@ type reviewEntry struct {
 	UpdatedAt  time.Time
 }
 
-func processFile(ctx context.Context, library *domain.Library, filePath string) error {
+// rowKey identifies a row of the export by a hash of its content
+func rowKey(line []string) string {
+	hash := sha256.New()
+	for _, field := range line {
+		hash.Write([]byte(field))
+		hash.Write([]byte{0})
+	}
+
+	return "goodreads:" + hex.EncodeToString(hash.Sum(nil))
+}
+
+func processFile(ctx context.Context, filePath string) ([]domain.ImportData, error) {
 	ctx, span := tr.Start(ctx, "process_file")
 	defer span.End()
 
 	reviews, err := readReviewsFile(ctx, filePath)
 	if err != nil {
-		return tracing.Error(span, err)
+		return nil, tracing.Error(span, err)
 	}
 
 	file, err := os.Open(filePath)
 	if err != nil {
-		return tracing.Error(span, err)
+		return nil, tracing.Error(span, err)
 	}
 	defer file.Close()
 
@@ -151,28 +167,28 @@ func processFile(ctx context.Context, library *domain.Library, filePath string)
 
 	// skip the headers
 	if _, err = reader.Read(); err != nil {
-		return tracing.Error(span, err)
+		return nil, tracing.Error(span, err)
 	}
 
-	lines := 0
+	rows := []domain.ImportData{}
 	for {
 		line, err := reader.Read()
 		if err == io.EOF {
 			break
 		}
 		if err != nil {
-			return tracing.Error(span, err)
+			return nil, tracing.Error(span, err)
 		}
-		lines++
 
-		if err := library.ImportBook(asBookImport(span, reviews, line)); err != nil {
-			return tracing.Error(span, err)
-		}
+		book := asBookImport(span, reviews, line)
+		book.ImportKey = rowKey(line)
+
+		rows = append(rows, book)
 	}
 
-	span.SetAttributes(attribute.Int("csv.lines", lines))
+	span.SetAttributes(attribute.Int("csv.lines", len(rows)))
 
-	return nil
+	return rows, nil
 }
 
 func asBookImport(span trace.Span, reviews map[string]reviewEntry, line []string) domain.ImportData {
@@ -251,6 +267,9 @@ func asBookImport(span trace.Span, reviews map[string]reviewEntry, line []string
 		}
 	}
 
+	// goodreads keeps the review's line breaks as html
+	review := strings.TrimSpace(strings.ReplaceAll(line[fieldMyReview], "<br/>", "\n"))
+
 	title := line[fieldTitle]
 	author := line[fieldAuthor]
 
@@ -263,6 +282,7 @@ func asBookImport(span trace.Span, reviews map[string]reviewEntry, line []string
 		Rating:    rating,
 		ReadCount: readCount,
 		Shelves:   shelves,
+		Review:    review,
 		DateAdded: dateAdded,
 		DateRead:  dateRead,
 	}
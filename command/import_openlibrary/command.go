@@ -7,6 +7,7 @@ import (
 	"fmt"
 	"io"
 	"kirjasto/config"
+	"kirjasto/openlibrary"
 	"kirjasto/storage"
 	"kirjasto/tracing"
 	"os"
@@ -50,7 +51,7 @@ func (c *ImportCommand) Execute(ctx context.Context, config *config.Config, args
 		return tracing.Error(span, err)
 	}
 
-	if err := c.createTables(ctx, writer); err != nil {
+	if err := openlibrary.CreateTables(ctx, writer); err != nil {
 		return tracing.Error(span, err)
 	}
 
@@ -81,55 +82,6 @@ func (c *ImportCommand) Execute(ctx context.Context, config *config.Config, args
 	return nil
 }
 
-func (c *ImportCommand) createTables(ctx context.Context, writer *sql.DB) error {
-	ctx, span := tr.Start(ctx, "create_tables")
-	defer span.End()
-
-	statements := []string{
-		`create table if not exists editions (
-			id text primary key,
-			data blob
-		)`,
-		`create virtual table if not exists editions_fts using fts5 (
-			edition_id,
-			title,
-			subtitle
-		)`,
-		`create table if not exists editions_works_link (
-			edition_id text,
-			work_id text,
-			foreign key(edition_id) references editions(id)
-		)`,
-		`create table if not exists editions_isbns_link (
-			edition_id text,
-			isbn text,
-			foreign key(edition_id) references editions(id)
-		)`,
-		`create table if not exists authors (
-			id text primary key,
-			data blob
-		)`,
-		`create virtual table if not exists authors_fts using fts5 (
-			author_id,
-			name
-		)`,
-		`create table if not exists editions_authors_link (
-			edition_id text,
-			author_id text,
-			foreign key(edition_id) references editions(id),
-			foreign key(author_id) references authors(id)
-		)`,
-	}
-
-	for _, statement := range statements {
-		if _, err := writer.ExecContext(ctx, statement); err != nil {
-			return tracing.Error(span, err)
-		}
-	}
-
-	return nil
-}
-
 func (c *ImportCommand) addIndexes(ctx context.Context, writer *sql.DB) error {
 	ctx, span := tr.Start(ctx, "add_indexes")
 	defer span.End()
@@ -4,8 +4,6 @@ import (
 	"context"
 	"kirjasto/config"
 	"kirjasto/domain"
-	"kirjasto/goes"
-	"kirjasto/storage"
 	"kirjasto/tracing"
 
 	"github.com/spf13/pflag"
@@ -47,29 +45,17 @@ func (c *AddCommand) Execute(ctx context.Context, config *config.Config, args []
 		return tracing.Errorf(span, "The books must have at least one of: isbn, title")
 	}
 
-	writer, err := storage.Writer(ctx, config.DatabaseFile)
+	store, err := openStore(ctx, config)
 	if err != nil {
 		return tracing.Error(span, err)
 	}
 
-	store := goes.NewSqliteStore(writer)
-
-	if err := store.RegisterProjection("library_view", domain.NewLibraryProjection()); err != nil {
-		return tracing.Error(span, err)
-	}
-
-	library, err := domain.LoadLibrary(ctx, store, domain.LibraryID)
+	err = domain.UpdateLibrary(ctx, store, domain.LibraryID, func(library *domain.Library) error {
+		return library.AddBook(c.book, c.tags)
+	})
 	if err != nil {
 		return tracing.Error(span, err)
 	}
 
-	if err := library.AddBook(c.book, c.tags); err != nil {
-		return tracing.Error(span, err)
-	}
-
-	if err := domain.SaveLibrary(ctx, store, library); err != nil {
-		return tracing.Error(span, err)
-	}
-
 	return nil
 }
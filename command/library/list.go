@@ -2,12 +2,15 @@ package library
 
 import (
 	"context"
+	"database/sql"
 	"fmt"
 	"kirjasto/config"
 	"kirjasto/domain"
+	"kirjasto/goes"
 	"kirjasto/storage"
 	"kirjasto/tracing"
 	"kirjasto/util/columnize"
+	"os"
 
 	"github.com/spf13/pflag"
 )
@@ -18,6 +21,8 @@ func NewListCommand() *ListCommand {
 
 type ListCommand struct {
 	statsOnly bool
+	asOf      string
+	filter    domain.BookFilter
 }
 
 func (c *ListCommand) Synopsis() string {
@@ -27,6 +32,10 @@ func (c *ListCommand) Synopsis() string {
 func (c *ListCommand) Flags() *pflag.FlagSet {
 	flags := pflag.NewFlagSet("list", pflag.ContinueOnError)
 	flags.BoolVar(&c.statsOnly, "stats", false, "print some stats and exit")
+	flags.StringVar(&c.filter.State, "state", "", "only list books in this state")
+	flags.IntVar(&c.filter.Limit, "limit", 0, "the maximum number of books to list")
+	flags.IntVar(&c.filter.Offset, "offset", 0, "how many books to skip before listing")
+	flags.StringVar(&c.asOf, "as-of", "", "list the library as it was at this date, time or sequence")
 	return flags
 }
 
@@ -34,29 +43,87 @@ func (c *ListCommand) Execute(ctx context.Context, config *config.Config, args [
 	ctx, span := tr.Start(ctx, "execute")
 	defer span.End()
 
-	reader, err := storage.Reader(ctx, config.DatabaseFile)
+	// the store is initialised so that the views are built if they are missing or stale
+	writer, err := storage.Writer(ctx, config.DatabaseFile)
 	if err != nil {
 		return tracing.Error(span, err)
 	}
 
-	p := domain.NewLibraryProjection()
-	library, err := p.View(ctx, reader, domain.LibraryID)
+	store := goes.NewSqliteStore(writer)
+	if err := domain.RegisterProjections(store); err != nil {
+		return tracing.Error(span, err)
+	}
+
+	if err := store.Initialise(ctx); err != nil {
+		return tracing.Error(span, err)
+	}
+
+	// an isolated view stops changing once it has failed, so isn't listed
+	faulted, err := store.Faulted(ctx, "library_books")
 	if err != nil {
 		return tracing.Error(span, err)
 	}
 
-	c.printStats(ctx, library)
+	var books []*domain.BookRow
+	var counts map[string]int
+
+	if c.asOf != "" {
+		point, err := goes.ParsePointInTime(c.asOf)
+		if err != nil {
+			return tracing.Error(span, err)
+		}
+
+		// the library_books table only holds the current state, so the past view is built in memory
+		view, err := domain.NewLibraryProjection().ViewAt(ctx, writer, store, domain.LibraryID, point)
+		if err == goes.ErrNotFound {
+			// the library didn't exist yet
+			view = &domain.LibraryView{}
+		} else if err != nil {
+			return tracing.Error(span, err)
+		}
+
+		books, counts = domain.BookRowsOf(view, c.filter)
+	} else if faulted {
+		fmt.Fprintln(os.Stderr, "Warning: the library_books view has failed to process an event (see goes failures), so the library's view is listed instead")
+
+		// the library's view is async, so is brought up to date before reading it
+		if _, err := store.CatchUp(ctx, goes.DefaultBatchSize); err != nil {
+			return tracing.Error(span, err)
+		}
+
+		view, err := domain.NewLibraryProjection().View(ctx, writer, domain.LibraryID)
+		if err == sql.ErrNoRows {
+			// nothing has been added to the library yet
+			view = &domain.LibraryView{}
+		} else if err != nil {
+			return tracing.Error(span, err)
+		}
+
+		books, counts = domain.BookRowsOf(view, c.filter)
+	} else {
+		p := domain.NewLibraryBooksProjection()
+
+		if counts, err = p.CountByState(ctx, writer); err != nil {
+			return tracing.Error(span, err)
+		}
+
+		if books, err = p.Books(ctx, writer, c.filter); err != nil {
+			return tracing.Error(span, err)
+		}
+	}
+
+	printStats(counts)
 	if c.statsOnly {
 		return nil
 	}
 
-	rows := make([]string, 0, len(library.Books)+1)
+	rows := make([]string, 0, len(books)+1)
 	rows = append(rows, "isbn | state | title | added")
 
-	for _, book := range library.Books {
+	for _, book := range books {
 		isbn := "unknown"
-		if len(book.Isbns) > 0 {
-			isbn = book.Isbns[0]
+		if book.Isbn != "" {
+			isbn = book.Isbn
 		}
 		rows = append(rows, fmt.Sprintf("%s | %s | %s | %s", isbn, book.State, book.Title, book.Added.Format("2006-01-02")))
 	}
@@ -66,18 +133,11 @@ func (c *ListCommand) Execute(ctx context.Context, config *config.Config, args [
 	return nil
 }
 
-func (c *ListCommand) printStats(ctx context.Context, library *domain.LibraryView) {
-	read := 0
-	unread := 0
-
-	for _, book := range library.Books {
-		switch book.State {
-		case "read":
-			read++
-		case "unread":
-			unread++
-		}
+func printStats(counts map[string]int) {
+	total := 0
+	for _, count := range counts {
+		total += count
 	}
 
-	fmt.Printf("Total books: %v (%v read, %v unread)\n", len(library.Books), read, unread)
+	fmt.Printf("Total books: %v (%v read, %v reading, %v unread)\n", total, counts[domain.StateRead], counts[domain.StateReading], counts[domain.StateUnread])
 }
@@ -0,0 +1,73 @@
+package library
+
+import (
+	"context"
+	"kirjasto/config"
+	"kirjasto/domain"
+	"kirjasto/openlibrary"
+	"kirjasto/storage"
+	"kirjasto/tracing"
+
+	"github.com/spf13/pflag"
+)
+
+func NewProgressCommand() *ProgressCommand {
+	return &ProgressCommand{}
+}
+
+type ProgressCommand struct {
+	when string
+}
+
+func (c *ProgressCommand) Synopsis() string {
+	return "record how far through a book you are, as a page (120 or 120/350) or a percentage (45%)"
+}
+
+func (c *ProgressCommand) Flags() *pflag.FlagSet {
+	flags := pflag.NewFlagSet("progress", pflag.ContinueOnError)
+	flags.StringVar(&c.when, "when", "", "the date of the progress, if not today")
+	return flags
+}
+
+func (c *ProgressCommand) Execute(ctx context.Context, config *config.Config, args []string) error {
+	ctx, span := tr.Start(ctx, "execute")
+	defer span.End()
+
+	if len(args) != 2 {
+		return tracing.Errorf(span, "this command takes exactly 2 arguments: the book's isbn, and the page or percentage")
+	}
+
+	progress, err := domain.ParseProgress(args[1])
+	if err != nil {
+		return tracing.Error(span, err)
+	}
+
+	when, err := parseDate(c.when)
+	if err != nil {
+		return tracing.Error(span, err)
+	}
+
+	if progress.Page > 0 && progress.Pages == 0 {
+		reader, err := storage.Reader(ctx, config.DatabaseFile)
+		if err != nil {
+			return tracing.Error(span, err)
+		}
+
+		// without the catalogue imported the book's length isn't known, and the page is
+		// recorded on its own
+		if pages, err := openlibrary.PageCount(ctx, reader, args[0]); err == nil {
+			progress.Pages = pages
+		} else {
+			span.RecordError(err)
+		}
+	}
+
+	err = updateLibrary(ctx, config, func(library *domain.Library) error {
+		return library.RecordProgress(args[0], progress, when)
+	})
+	if err != nil {
+		return tracing.Error(span, err)
+	}
+
+	return nil
+}
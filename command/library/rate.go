@@ -0,0 +1,72 @@
+package library
+
+import (
+	"context"
+	"kirjasto/config"
+	"kirjasto/domain"
+	"kirjasto/tracing"
+	"time"
+
+	"github.com/spf13/pflag"
+)
+
+func NewRateCommand() *RateCommand {
+	return &RateCommand{}
+}
+
+type RateCommand struct {
+	review       string
+	deleteReview bool
+}
+
+func (c *RateCommand) Synopsis() string {
+	return "rate a book out of 5 stars, in half stars, and optionally review it"
+}
+
+func (c *RateCommand) Flags() *pflag.FlagSet {
+	flags := pflag.NewFlagSet("rate", pflag.ContinueOnError)
+	flags.StringVar(&c.review, "review", "", "the review of the book, in markdown, replacing any existing review")
+	flags.BoolVar(&c.deleteReview, "delete-review", false, "delete the book's review")
+	return flags
+}
+
+func (c *RateCommand) Execute(ctx context.Context, config *config.Config, args []string) error {
+	ctx, span := tr.Start(ctx, "execute")
+	defer span.End()
+
+	if len(args) != 2 {
+		return tracing.Errorf(span, "this command takes exactly 2 arguments: the book's isbn, and the rating (0 to remove it)")
+	}
+
+	if c.review != "" && c.deleteReview {
+		return tracing.Errorf(span, "--review and --delete-review can't be used together")
+	}
+
+	rating, err := domain.ParseRating(args[1])
+	if err != nil {
+		return tracing.Error(span, err)
+	}
+
+	err = updateLibrary(ctx, config, func(library *domain.Library) error {
+		now := time.Now()
+
+		if err := library.RateBook(args[0], rating, now); err != nil {
+			return err
+		}
+
+		if c.review != "" {
+			return library.ReviewBook(args[0], c.review, now)
+		}
+
+		if c.deleteReview {
+			return library.DeleteReview(args[0], now)
+		}
+
+		return nil
+	})
+	if err != nil {
+		return tracing.Error(span, err)
+	}
+
+	return nil
+}
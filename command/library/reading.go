@@ -0,0 +1,102 @@
+package library
+
+import (
+	"context"
+	"kirjasto/config"
+	"kirjasto/domain"
+	"kirjasto/tracing"
+	"time"
+
+	"github.com/spf13/pflag"
+)
+
+func NewStartCommand() *StartCommand {
+	return &StartCommand{}
+}
+
+type StartCommand struct {
+	when string
+}
+
+func (c *StartCommand) Synopsis() string {
+	return "start reading a book"
+}
+
+func (c *StartCommand) Flags() *pflag.FlagSet {
+	flags := pflag.NewFlagSet("start", pflag.ContinueOnError)
+	flags.StringVar(&c.when, "when", "", "the date the book was started, if not today")
+	return flags
+}
+
+func (c *StartCommand) Execute(ctx context.Context, config *config.Config, args []string) error {
+	ctx, span := tr.Start(ctx, "execute")
+	defer span.End()
+
+	if len(args) != 1 {
+		return tracing.Errorf(span, "this command takes exactly 1 argument: the book's isbn")
+	}
+
+	when, err := parseDate(c.when)
+	if err != nil {
+		return tracing.Error(span, err)
+	}
+
+	err = updateLibrary(ctx, config, func(library *domain.Library) error {
+		return library.StartReading(args[0], when)
+	})
+	if err != nil {
+		return tracing.Error(span, err)
+	}
+
+	return nil
+}
+
+func NewFinishCommand() *FinishCommand {
+	return &FinishCommand{}
+}
+
+type FinishCommand struct {
+	when string
+}
+
+func (c *FinishCommand) Synopsis() string {
+	return "finish reading a book"
+}
+
+func (c *FinishCommand) Flags() *pflag.FlagSet {
+	flags := pflag.NewFlagSet("finish", pflag.ContinueOnError)
+	flags.StringVar(&c.when, "when", "", "the date the book was finished, if not today")
+	return flags
+}
+
+func (c *FinishCommand) Execute(ctx context.Context, config *config.Config, args []string) error {
+	ctx, span := tr.Start(ctx, "execute")
+	defer span.End()
+
+	if len(args) != 1 {
+		return tracing.Errorf(span, "this command takes exactly 1 argument: the book's isbn")
+	}
+
+	when, err := parseDate(c.when)
+	if err != nil {
+		return tracing.Error(span, err)
+	}
+
+	err = updateLibrary(ctx, config, func(library *domain.Library) error {
+		return library.FinishReading(args[0], when)
+	})
+	if err != nil {
+		return tracing.Error(span, err)
+	}
+
+	return nil
+}
+
+// parseDate reads a yyyy-mm-dd date, with an empty value being the zero time
+func parseDate(value string) (time.Time, error) {
+	if value == "" {
+		return time.Time{}, nil
+	}
+
+	return time.ParseInLocation(time.DateOnly, value, time.Local)
+}
@@ -0,0 +1,54 @@
+package library
+
+import (
+	"context"
+	"kirjasto/config"
+	"kirjasto/domain"
+	"kirjasto/tracing"
+	"strings"
+
+	"github.com/spf13/pflag"
+)
+
+func NewRemoveCommand() *RemoveCommand {
+	return &RemoveCommand{}
+}
+
+type RemoveCommand struct {
+	reason string
+	when   string
+}
+
+func (c *RemoveCommand) Synopsis() string {
+	return "remove a book from the library"
+}
+
+func (c *RemoveCommand) Flags() *pflag.FlagSet {
+	flags := pflag.NewFlagSet("remove", pflag.ContinueOnError)
+	flags.StringVar(&c.reason, "reason", "", "why the book is leaving the library: "+strings.Join(domain.RemovalReasons, ", "))
+	flags.StringVar(&c.when, "when", "", "the date the book was removed, if not today")
+	return flags
+}
+
+func (c *RemoveCommand) Execute(ctx context.Context, config *config.Config, args []string) error {
+	ctx, span := tr.Start(ctx, "execute")
+	defer span.End()
+
+	if len(args) != 1 {
+		return tracing.Errorf(span, "this command takes exactly 1 argument: the book's isbn")
+	}
+
+	when, err := parseDate(c.when)
+	if err != nil {
+		return tracing.Error(span, err)
+	}
+
+	err = updateLibrary(ctx, config, func(library *domain.Library) error {
+		return library.RemoveBook(args[0], c.reason, when)
+	})
+	if err != nil {
+		return tracing.Error(span, err)
+	}
+
+	return nil
+}
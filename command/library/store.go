@@ -0,0 +1,23 @@
+package library
+
+import (
+	"context"
+	"kirjasto/config"
+	"kirjasto/domain"
+	"kirjasto/goes"
+)
+
+// openStore opens the event store for commands which change the library
+func openStore(ctx context.Context, config *config.Config) (*goes.SqliteStore, error) {
+	return domain.OpenStore(ctx, config.DatabaseFile, config.StoreOptions()...)
+}
+
+// updateLibrary runs the command against the library, in a newly opened store
+func updateLibrary(ctx context.Context, config *config.Config, command func(library *domain.Library) error) error {
+	store, err := openStore(ctx, config)
+	if err != nil {
+		return err
+	}
+
+	return domain.UpdateLibrary(ctx, store, domain.LibraryID, command)
+}
@@ -0,0 +1,197 @@
+package library
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"kirjasto/config"
+	"kirjasto/domain"
+	"kirjasto/goes"
+	"kirjasto/storage"
+	"kirjasto/tracing"
+	"kirjasto/util/columnize"
+
+	"github.com/spf13/pflag"
+)
+
+func NewTagsListCommand() *TagsListCommand {
+	return &TagsListCommand{}
+}
+
+type TagsListCommand struct{}
+
+func (c *TagsListCommand) Synopsis() string {
+	return "list the tags, with how many books have each"
+}
+
+func (c *TagsListCommand) Flags() *pflag.FlagSet {
+	return pflag.NewFlagSet("tags", pflag.ContinueOnError)
+}
+
+func (c *TagsListCommand) Execute(ctx context.Context, config *config.Config, args []string) error {
+	ctx, span := tr.Start(ctx, "execute")
+	defer span.End()
+
+	store, err := openStore(ctx, config)
+	if err != nil {
+		return tracing.Error(span, err)
+	}
+
+	// the view is async, so is brought up to date with the events before reading it
+	if _, err := store.CatchUp(ctx, goes.DefaultBatchSize); err != nil {
+		return tracing.Error(span, err)
+	}
+
+	reader, err := storage.Reader(ctx, config.DatabaseFile)
+	if err != nil {
+		return tracing.Error(span, err)
+	}
+
+	view, err := domain.NewLibraryProjection().View(ctx, reader, domain.LibraryID)
+	if err == sql.ErrNoRows {
+		// nothing has been added to the library yet
+		view = &domain.LibraryView{}
+	} else if err != nil {
+		return tracing.Error(span, err)
+	}
+
+	tags := view.Tags()
+
+	rows := make([]string, 0, len(tags)+1)
+	rows = append(rows, "tag | books")
+	for _, tag := range tags {
+		rows = append(rows, fmt.Sprintf("%s | %d", tag.Tag, tag.Count))
+	}
+
+	fmt.Println(columnize.SimpleFormat(rows))
+
+	return nil
+}
+
+func NewTagsAddCommand() *TagsAddCommand {
+	return &TagsAddCommand{}
+}
+
+type TagsAddCommand struct{}
+
+func (c *TagsAddCommand) Synopsis() string {
+	return "add tags to a book"
+}
+
+func (c *TagsAddCommand) Flags() *pflag.FlagSet {
+	return pflag.NewFlagSet("add", pflag.ContinueOnError)
+}
+
+func (c *TagsAddCommand) Execute(ctx context.Context, config *config.Config, args []string) error {
+	ctx, span := tr.Start(ctx, "execute")
+	defer span.End()
+
+	if len(args) < 2 {
+		return tracing.Errorf(span, "this command takes the book's isbn, and at least one tag")
+	}
+
+	err := updateLibrary(ctx, config, func(library *domain.Library) error {
+		return library.TagBook(args[0], args[1:]...)
+	})
+	if err != nil {
+		return tracing.Error(span, err)
+	}
+
+	return nil
+}
+
+func NewTagsRemoveCommand() *TagsRemoveCommand {
+	return &TagsRemoveCommand{}
+}
+
+type TagsRemoveCommand struct{}
+
+func (c *TagsRemoveCommand) Synopsis() string {
+	return "remove tags from a book"
+}
+
+func (c *TagsRemoveCommand) Flags() *pflag.FlagSet {
+	return pflag.NewFlagSet("remove", pflag.ContinueOnError)
+}
+
+func (c *TagsRemoveCommand) Execute(ctx context.Context, config *config.Config, args []string) error {
+	ctx, span := tr.Start(ctx, "execute")
+	defer span.End()
+
+	if len(args) < 2 {
+		return tracing.Errorf(span, "this command takes the book's isbn, and at least one tag")
+	}
+
+	err := updateLibrary(ctx, config, func(library *domain.Library) error {
+		return library.UntagBook(args[0], args[1:]...)
+	})
+	if err != nil {
+		return tracing.Error(span, err)
+	}
+
+	return nil
+}
+
+func NewTagsRenameCommand() *TagsRenameCommand {
+	return &TagsRenameCommand{}
+}
+
+type TagsRenameCommand struct{}
+
+func (c *TagsRenameCommand) Synopsis() string {
+	return "rename a tag on every book"
+}
+
+func (c *TagsRenameCommand) Flags() *pflag.FlagSet {
+	return pflag.NewFlagSet("rename", pflag.ContinueOnError)
+}
+
+func (c *TagsRenameCommand) Execute(ctx context.Context, config *config.Config, args []string) error {
+	ctx, span := tr.Start(ctx, "execute")
+	defer span.End()
+
+	if len(args) != 2 {
+		return tracing.Errorf(span, "this command takes exactly 2 arguments: the tag, and its new name")
+	}
+
+	err := updateLibrary(ctx, config, func(library *domain.Library) error {
+		return library.RenameTag(args[0], args[1])
+	})
+	if err != nil {
+		return tracing.Error(span, err)
+	}
+
+	return nil
+}
+
+func NewTagsMergeCommand() *TagsMergeCommand {
+	return &TagsMergeCommand{}
+}
+
+type TagsMergeCommand struct{}
+
+func (c *TagsMergeCommand) Synopsis() string {
+	return "merge tags into one tag, on every book"
+}
+
+func (c *TagsMergeCommand) Flags() *pflag.FlagSet {
+	return pflag.NewFlagSet("merge", pflag.ContinueOnError)
+}
+
+func (c *TagsMergeCommand) Execute(ctx context.Context, config *config.Config, args []string) error {
+	ctx, span := tr.Start(ctx, "execute")
+	defer span.End()
+
+	if len(args) < 2 {
+		return tracing.Errorf(span, "this command takes the tag to merge into, and at least one tag to merge")
+	}
+
+	err := updateLibrary(ctx, config, func(library *domain.Library) error {
+		return library.MergeTags(args[0], args[1:]...)
+	})
+	if err != nil {
+		return tracing.Error(span, err)
+	}
+
+	return nil
+}
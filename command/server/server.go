@@ -4,9 +4,15 @@ import (
 	"context"
 	"fmt"
 	"kirjasto/config"
+	"kirjasto/domain"
+	"kirjasto/goes"
+	"kirjasto/storage"
 	"kirjasto/tracing"
 	"kirjasto/ui"
+	"kirjasto/webhook"
 	"net/http"
+	"os"
+	"time"
 
 	"github.com/spf13/pflag"
 	"go.opentelemetry.io/otel"
@@ -21,7 +27,8 @@ func NewServerCommand() *ServerCommand {
 }
 
 type ServerCommand struct {
-	address string
+	address    string
+	staleViews string
 }
 
 func (c *ServerCommand) Synopsis() string {
@@ -31,6 +38,7 @@ func (c *ServerCommand) Synopsis() string {
 func (c *ServerCommand) Flags() *pflag.FlagSet {
 	flags := pflag.NewFlagSet("server", pflag.ContinueOnError)
 	flags.StringVar(&c.address, "address", "localhost:4400", "host:port")
+	flags.StringVar(&c.staleViews, "stale-views", "rebuild", "what to do with views whose projection has changed: rebuild or refuse to start")
 	return flags
 }
 
@@ -38,15 +46,52 @@ func (c *ServerCommand) Execute(ctx context.Context, config *config.Config, args
 	ctx, span := tr.Start(ctx, "execute")
 	defer span.End()
 
+	stalePolicy, err := goes.ParseStalePolicy(c.staleViews)
+	if err != nil {
+		return tracing.Error(span, err)
+	}
+
+	db, err := storage.Writer(ctx, config.DatabaseFile)
+	if err != nil {
+		return tracing.Error(span, err)
+	}
+
+	// the events are read without loading the library when viewing it in the past
+	domain.RegisterEvents()
+
+	eventStore := goes.NewSqliteStore(db, append(config.StoreOptions(), goes.WithStalePolicy(stalePolicy))...)
+	if err := domain.RegisterProjections(eventStore); err != nil {
+		return tracing.Error(span, err)
+	}
+
+	if err := eventStore.Initialise(ctx); err != nil {
+		return tracing.Error(span, err)
+	}
+
+	go func() {
+		if err := eventStore.RunProjector(ctx, goes.DefaultBatchSize, time.Second); err != nil {
+			fmt.Fprintln(os.Stderr, "Projector stopped:", tracing.Error(span, err))
+		}
+	}()
+
+	if len(config.WebhookUrls) > 0 {
+		deliverer := webhook.NewDeliverer(eventStore, config.WebhookSecret)
+		go func() {
+			if err := deliverer.Run(ctx, 5*time.Second); err != nil {
+				fmt.Fprintln(os.Stderr, "Webhook delivery stopped:", tracing.Error(span, err))
+			}
+		}()
+	}
+
 	mux := http.NewServeMux()
 
-	if err := ui.RegisterUI(ctx, config, mux); err != nil {
+	if err := ui.RegisterUI(ctx, config, mux, eventStore); err != nil {
 		return tracing.Error(span, err)
 	}
 
 	server := &http.Server{
 		Addr:    c.address,
-		Handler: otelhttp.NewHandler(mux, "mux"),
+		Handler: otelhttp.NewHandler(withEventMetadata(mux), "mux"),
 	}
 
 	fmt.Println("Listening on", server.Addr)
@@ -61,3 +106,33 @@ func (c *ServerCommand) Execute(ctx context.Context, config *config.Config, args
 
 	return nil
 }
+
+func withEventMetadata(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx := goes.WithMetadata(r.Context(), goes.MetadataRequest, r.Method+" "+r.URL.Path)
+
+		// a resubmitted form or retried request saves nothing new
+		if r.Method != http.MethodGet {
+			if key := requestID(r); key != "" {
+				ctx = goes.WithIdempotencyKey(ctx, "request:"+key)
+			}
+		}
+
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
+
+// requestID is the client's id for the request, from either the Idempotency-Key or
+// X-Request-Id header, or a request_id form field.  The UI's forms are given a new
+// request_id each time they are rendered, by the template's requestID func.
+func requestID(r *http.Request) string {
+	if key := r.Header.Get("Idempotency-Key"); key != "" {
+		return key
+	}
+
+	if key := r.Header.Get("X-Request-Id"); key != "" {
+		return key
+	}
+
+	return r.PostFormValue("request_id")
+}
@@ -2,14 +2,81 @@ package config
 
 import (
 	"context"
+	"errors"
+	"kirjasto/goes"
+	"os"
+	"os/user"
+	"strings"
 )
 
 type Config struct {
 	DatabaseFile string
+	Actor        string
+
+	// HashChain starts the event store's hash chain, see goes.WithHashChain
+	HashChain bool
+
+	// WebhookUrls are sent the WebhookEvents by the server, signed with the WebhookSecret
+	WebhookUrls   []string
+	WebhookEvents []string
+	WebhookSecret string
 }
 
 func CreateConfig(ctx context.Context) (*Config, error) {
-	return &Config{
+	config := &Config{
 		DatabaseFile: "dev.sqlite",
-	}, nil
+		Actor:        currentActor(),
+		HashChain:    os.Getenv("KIRJASTO_HASH_CHAIN") == "true",
+
+		WebhookUrls:   listVariable("KIRJASTO_WEBHOOK_URLS", ""),
+		WebhookEvents: listVariable("KIRJASTO_WEBHOOK_EVENTS", "BookAdded,BookImported,BookFinished"),
+		WebhookSecret: os.Getenv("KIRJASTO_WEBHOOK_SECRET"),
+	}
+
+	// anyone could sign a webhook with an empty secret
+	if len(config.WebhookUrls) > 0 && config.WebhookSecret == "" {
+		return nil, errors.New("KIRJASTO_WEBHOOK_SECRET must be set when KIRJASTO_WEBHOOK_URLS is")
+	}
+
+	return config, nil
+}
+
+// StoreOptions are the options for event stores which save events
+func (c *Config) StoreOptions() []goes.SqliteOption {
+	options := []goes.SqliteOption{}
+	if c.HashChain {
+		options = append(options, goes.WithHashChain())
+	}
+	for _, url := range c.WebhookUrls {
+		options = append(options, goes.WithOutbox(url, c.WebhookEvents...))
+	}
+	return options
+}
+
+// listVariable reads a comma separated environment variable
+func listVariable(name string, defaultValue string) []string {
+	value, found := os.LookupEnv(name)
+	if !found {
+		value = defaultValue
+	}
+
+	values := []string{}
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			values = append(values, item)
+		}
+	}
+	return values
+}
+
+func currentActor() string {
+	if actor := os.Getenv("KIRJASTO_ACTOR"); actor != "" {
+		return actor
+	}
+
+	if current, err := user.Current(); err == nil {
+		return current.Username
+	}
+
+	return ""
 }
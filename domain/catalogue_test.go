@@ -0,0 +1,64 @@
+package domain
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"kirjasto/openlibrary"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+type catalogueEdition struct {
+	Key    string
+	Title  string
+	Isbn   string
+	Author string
+}
+
+// seedCatalogue creates the openlibrary tables with just the editions given, each with
+// its own work and author.
+func seedCatalogue(t testing.TB, db *sql.DB, editions ...catalogueEdition) {
+	ctx := context.Background()
+
+	// the catalogue's search tables need sqlite to be built with fts5
+	if err := openlibrary.CreateTables(ctx, db); err != nil && strings.Contains(err.Error(), "no such module: fts5") {
+		t.Skip("the catalogue needs building with -tags fts5")
+	} else {
+		require.NoError(t, err)
+	}
+
+	for _, edition := range editions {
+		authorKey := "/authors/" + edition.Key + "A"
+
+		editionJson, err := json.Marshal(map[string]any{
+			"key":     "/books/" + edition.Key,
+			"title":   edition.Title,
+			"isbn_13": []string{edition.Isbn},
+			"works":   []map[string]string{{"key": "/works/" + edition.Key + "W"}},
+			"authors": []map[string]string{{"key": authorKey}},
+		})
+		require.NoError(t, err)
+
+		authorJson, err := json.Marshal(map[string]string{"key": authorKey, "name": edition.Author})
+		require.NoError(t, err)
+
+		statements := []struct {
+			query string
+			args  []any
+		}{
+			{"insert into editions (id, data) values (?, ?)", []any{edition.Key, editionJson}},
+			{"insert into editions_isbns_link (edition_id, isbn) values (?, ?)", []any{edition.Key, edition.Isbn}},
+			{"insert into editions_fts (edition_id, title, subtitle) values (?, ?, '')", []any{edition.Key, edition.Title}},
+			{"insert into authors (id, data) values (?, ?)", []any{authorKey, authorJson}},
+			{"insert into editions_authors_link (edition_id, author_id) values (?, ?)", []any{edition.Key, authorKey}},
+		}
+
+		for _, statement := range statements {
+			_, err := db.ExecContext(ctx, statement.query, statement.args...)
+			require.NoError(t, err)
+		}
+	}
+}
@@ -2,8 +2,16 @@ package domain
 
 import (
 	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
 	"kirjasto/goes"
 	"kirjasto/tracing"
+	"maps"
+	"math"
+	"slices"
+	"strconv"
+	"strings"
 	"time"
 
 	"github.com/google/uuid"
@@ -14,10 +22,21 @@ var tr = otel.Tracer("domain")
 
 var LibraryID uuid.UUID = uuid.MustParse("89ea74d8-1960-41cc-b795-2d843f02c0aa")
 
+const LibraryType = "library"
+
+var ErrUnknownBook = errors.New("the book is not in the library")
+var ErrNotReading = errors.New("the book is not being read")
+
 func blankLibrary() *Library {
 	library := &Library{
-		state:      goes.NewAggregateState(),
+		state:      goes.NewAggregateState(LibraryType),
 		knownIsbns: map[string]bool{},
+		bookKeys:   map[string]string{},
+		reading:    map[string]time.Time{},
+		ratings:    map[string]float64{},
+		reviewed:   map[string]bool{},
+		tags:       map[string][]string{},
+		importKeys: map[string]bool{},
 	}
 
 	goes.Register(library.state, library.onLibraryCreated)
@@ -25,10 +44,27 @@ func blankLibrary() *Library {
 	goes.Register(library.state, library.onBookAdded)
 	goes.Register(library.state, library.onBookStarted)
 	goes.Register(library.state, library.onBookFinished)
+	goes.Register(library.state, library.onReadingProgressed)
+	goes.Register(library.state, library.onBookRated)
+	goes.Register(library.state, library.onBookReviewed)
+	goes.Register(library.state, library.onBookReviewDeleted)
+	goes.Register(library.state, library.onBookTagged)
+	goes.Register(library.state, library.onBookUntagged)
+	goes.Register(library.state, library.onTagRenamed)
+	goes.Register(library.state, library.onTagsMerged)
+	goes.Register(library.state, library.onBookRemoved)
+
+	goes.EnableSnapshots(library.state, library, goes.EveryNEvents(100))
 
 	return library
 }
 
+// RegisterEvents makes the library's event types known to goes, for when events need to
+// be read without loading a Library first, such as when importing.
+func RegisterEvents() {
+	blankLibrary()
+}
+
 func NewLibrary(id uuid.UUID) *Library {
 	library := blankLibrary()
 
@@ -39,7 +75,7 @@ func NewLibrary(id uuid.UUID) *Library {
 	return library
 }
 
-func LoadLibrary(ctx context.Context, eventStore *goes.SqliteStore, id uuid.UUID) (*Library, error) {
+func LoadLibrary(ctx context.Context, eventStore goes.Store, id uuid.UUID) (*Library, error) {
 	ctx, span := tr.Start(ctx, "load_library")
 	defer span.End()
 
@@ -53,14 +89,108 @@ func LoadLibrary(ctx context.Context, eventStore *goes.SqliteStore, id uuid.UUID
 	return library, nil
 }
 
-func SaveLibrary(ctx context.Context, eventStore *goes.SqliteStore, library *Library) error {
+func SaveLibrary(ctx context.Context, eventStore goes.Store, library *Library) error {
 	return goes.Save(ctx, eventStore, library.state)
 }
 
+// UpdateLibrary runs the command against the library, creating it if it doesn't exist yet,
+// and retries if another process saves to the library at the same time.
+func UpdateLibrary(ctx context.Context, eventStore goes.Store, id uuid.UUID, command func(library *Library) error, options ...goes.ExecuteOption) error {
+	load := func(ctx context.Context, eventStore goes.Store) (*Library, error) {
+		library, err := LoadLibrary(ctx, eventStore, id)
+		if err == goes.ErrNotFound {
+			return NewLibrary(id), nil
+		}
+		return library, err
+	}
+
+	return goes.Execute(ctx, eventStore, load, SaveLibrary, command, options...)
+}
+
 type Library struct {
 	state *goes.AggregateState
 
 	knownIsbns map[string]bool
+
+	// bookKeys maps each isbn to the book's first isbn, which identifies the book in
+	// reading events
+	bookKeys map[string]string
+
+	// reading is when each book in progress was started, by the book's first isbn
+	reading map[string]time.Time
+
+	// ratings and reviewed are which books have a rating or a review, by the book's
+	// first isbn
+	ratings  map[string]float64
+	reviewed map[string]bool
+
+	// tags are each book's tags, by the book's key, so that tags can be renamed across
+	// all of the books, including those without an isbn
+	tags map[string][]string
+
+	// importKeys are the rows which books have been imported from
+	importKeys map[string]bool
+}
+
+type librarySnapshot struct {
+	KnownIsbns []string
+	BookKeys   map[string]string
+	Reading    map[string]time.Time
+	Ratings    map[string]float64
+	Reviewed   []string
+	Tags       map[string][]string
+	ImportKeys []string
+}
+
+func (l *Library) SnapshotVersion() int {
+	return 5
+}
+
+func (l *Library) TakeSnapshot() (any, error) {
+	snapshot := librarySnapshot{
+		KnownIsbns: make([]string, 0, len(l.knownIsbns)),
+		BookKeys:   l.bookKeys,
+		Reading:    l.reading,
+		Ratings:    l.ratings,
+		Reviewed:   make([]string, 0, len(l.reviewed)),
+		Tags:       l.tags,
+		ImportKeys: make([]string, 0, len(l.importKeys)),
+	}
+
+	for isbn := range l.knownIsbns {
+		snapshot.KnownIsbns = append(snapshot.KnownIsbns, isbn)
+	}
+	for key := range l.reviewed {
+		snapshot.Reviewed = append(snapshot.Reviewed, key)
+	}
+	for key := range l.importKeys {
+		snapshot.ImportKeys = append(snapshot.ImportKeys, key)
+	}
+
+	return snapshot, nil
+}
+
+func (l *Library) RestoreSnapshot(data []byte) error {
+	snapshot := librarySnapshot{}
+	if err := json.Unmarshal(data, &snapshot); err != nil {
+		return err
+	}
+
+	for _, isbn := range snapshot.KnownIsbns {
+		l.knownIsbns[isbn] = true
+	}
+	maps.Copy(l.bookKeys, snapshot.BookKeys)
+	maps.Copy(l.reading, snapshot.Reading)
+	maps.Copy(l.ratings, snapshot.Ratings)
+	maps.Copy(l.tags, snapshot.Tags)
+	for _, key := range snapshot.Reviewed {
+		l.reviewed[key] = true
+	}
+	for _, key := range snapshot.ImportKeys {
+		l.importKeys[key] = true
+	}
+
+	return nil
 }
 
 type LibraryCreated struct {
@@ -72,6 +202,10 @@ func (l *Library) onLibraryCreated(e LibraryCreated) {
 }
 
 type ImportData struct {
+	// ImportKey identifies where the book was imported from, such as a row of an export,
+	// so that importing it again does nothing
+	ImportKey string
+
 	Isbns       []string
 	Title       string
 	Author      string
@@ -80,17 +214,20 @@ type ImportData struct {
 	Rating    int
 	ReadCount int
 	Shelves   []string
+	Review    string
 
 	DateAdded time.Time
 	DateRead  time.Time
 }
 
 type BookImported struct {
-	Book BookInfo
+	Book      BookInfo
+	ImportKey string
 
 	Tags      []string
 	Rating    int
 	ReadCount int
+	Review    string `goes:"personal"`
 
 	DateAdded time.Time
 	DateRead  time.Time
@@ -98,6 +235,10 @@ type BookImported struct {
 
 func (l *Library) ImportBook(info ImportData) error {
 
+	if info.ImportKey != "" && l.importKeys[info.ImportKey] {
+		return nil
+	}
+
 	for _, isbn := range info.Isbns {
 		if _, found := l.knownIsbns[isbn]; found {
 			return nil
@@ -105,6 +246,7 @@ func (l *Library) ImportBook(info ImportData) error {
 	}
 
 	return goes.Apply(l.state, BookImported{
+		ImportKey: info.ImportKey,
 		Book: BookInfo{
 			Isbns:       info.Isbns,
 			Title:       info.Title,
@@ -115,6 +257,7 @@ func (l *Library) ImportBook(info ImportData) error {
 		Rating:    info.Rating,
 		ReadCount: info.ReadCount,
 		Tags:      info.Shelves,
+		Review:    info.Review,
 
 		DateAdded: info.DateAdded,
 		DateRead:  info.DateRead,
@@ -122,9 +265,20 @@ func (l *Library) ImportBook(info ImportData) error {
 }
 
 func (l *Library) onBookImported(e BookImported) {
+	if e.ImportKey != "" {
+		l.importKeys[e.ImportKey] = true
+	}
+	l.addIsbns(e.Book)
+	l.tags[bookKey(e.Book)] = cleanTags(e.Tags)
 
-	for _, isbn := range e.Book.Isbns {
-		l.knownIsbns[isbn] = true
+	if len(e.Book.Isbns) == 0 {
+		return
+	}
+	if e.Rating > 0 {
+		l.ratings[e.Book.Isbns[0]] = float64(e.Rating)
+	}
+	if e.Review != "" {
+		l.reviewed[e.Book.Isbns[0]] = true
 	}
 }
 
@@ -157,29 +311,110 @@ func (l *Library) AddBook(book BookInfo, tags []string) error {
 }
 
 func (l *Library) onBookAdded(e BookAdded) {
-	for _, isbn := range e.Book.Isbns {
+	l.addIsbns(e.Book)
+	l.tags[bookKey(e.Book)] = cleanTags(e.Tags)
+}
+
+func (l *Library) addIsbns(book BookInfo) {
+	for _, isbn := range book.Isbns {
 		l.knownIsbns[isbn] = true
+		l.bookKeys[isbn] = book.Isbns[0]
 	}
 }
 
+// BookRemoved is a book leaving the library, for one of the RemovalReasons
+type BookRemoved struct {
+	Isbn   string
+	Reason string
+	When   time.Time
+}
+
+const (
+	RemovedGivenAway = "given-away"
+	RemovedSold      = "sold"
+	RemovedLost      = "lost"
+	RemovedDuplicate = "duplicate"
+)
+
+var RemovalReasons = []string{RemovedGivenAway, RemovedSold, RemovedLost, RemovedDuplicate}
+
+// RemoveBook takes the book out of the library, after which none of its isbns are known
+// so it can be added again later.
+func (l *Library) RemoveBook(isbn string, reason string, when time.Time) error {
+	if when.IsZero() {
+		when = time.Now()
+	}
+
+	if !slices.Contains(RemovalReasons, reason) {
+		return fmt.Errorf("%s is not a reason to remove a book, it must be one of: %s", reason, strings.Join(RemovalReasons, ", "))
+	}
+
+	key, err := l.bookKey(isbn)
+	if err != nil {
+		return err
+	}
+
+	return goes.Apply(l.state, BookRemoved{
+		Isbn:   key,
+		Reason: reason,
+		When:   when,
+	})
+}
+
+func (l *Library) onBookRemoved(e BookRemoved) {
+	for isbn, key := range l.bookKeys {
+		if key == e.Isbn {
+			delete(l.bookKeys, isbn)
+			delete(l.knownIsbns, isbn)
+		}
+	}
+
+	delete(l.reading, e.Isbn)
+	delete(l.ratings, e.Isbn)
+	delete(l.reviewed, e.Isbn)
+	delete(l.tags, e.Isbn)
+}
+
+// bookKey finds the first isbn of the book with the given isbn, which is how reading
+// events refer to the book
+func (l *Library) bookKey(isbn string) (string, error) {
+	key, found := l.bookKeys[isbn]
+	if !found {
+		return "", fmt.Errorf("%w: %s", ErrUnknownBook, isbn)
+	}
+
+	return key, nil
+}
+
 type BookStarted struct {
 	Isbn string
 	When time.Time
 }
 
+// StartReading starts a session of reading the book.  Starting a book which is already
+// being read does nothing.
 func (l *Library) StartReading(isbn string, when time.Time) error {
 	if when.IsZero() {
 		when = time.Now()
 	}
 
+	key, err := l.bookKey(isbn)
+	if err != nil {
+		return err
+	}
+
+	if _, found := l.reading[key]; found {
+		return nil
+	}
+
 	return goes.Apply(l.state, BookStarted{
-		Isbn: isbn,
+		Isbn: key,
 		When: when,
 	})
 }
 
 func (l *Library) onBookStarted(e BookStarted) {
-	// ?
+	l.reading[e.Isbn] = e.When
 }
 
 type BookFinished struct {
@@ -187,17 +422,265 @@ type BookFinished struct {
 	When time.Time
 }
 
+// FinishReading ends the current session of reading the book, which must have been
+// started first.
 func (l *Library) FinishReading(isbn string, when time.Time) error {
 	if when.IsZero() {
 		when = time.Now()
 	}
 
+	key, err := l.bookKey(isbn)
+	if err != nil {
+		return err
+	}
+
+	started, found := l.reading[key]
+	if !found {
+		return fmt.Errorf("%w: %s", ErrNotReading, isbn)
+	}
+
+	if when.Before(started) {
+		return fmt.Errorf("%s can't be finished before it was started, on %s", isbn, started.Format(time.DateOnly))
+	}
+
 	return goes.Apply(l.state, BookFinished{
-		Isbn: isbn,
+		Isbn: key,
 		When: when,
 	})
 }
 
 func (l *Library) onBookFinished(e BookFinished) {
-	// ?
+	delete(l.reading, e.Isbn)
+}
+
+// Progress is how far through a book the reader is, as either a page or a percentage.
+// Pages is the length of the book, when it is known.
+type Progress struct {
+	Page    int
+	Pages   int
+	Percent int
+}
+
+// ParseProgress reads a page ("120"), a page of a total ("120/350") or a percentage ("45%").
+func ParseProgress(value string) (Progress, error) {
+	value = strings.TrimSpace(value)
+
+	if percent, found := strings.CutSuffix(value, "%"); found {
+		p, err := strconv.Atoi(strings.TrimSpace(percent))
+		if err != nil {
+			return Progress{}, fmt.Errorf("%s is not a percentage", value)
+		}
+		return Progress{Percent: p}, nil
+	}
+
+	page, pages, _ := strings.Cut(value, "/")
+
+	progress := Progress{}
+	var err error
+	if progress.Page, err = strconv.Atoi(strings.TrimSpace(page)); err != nil {
+		return Progress{}, fmt.Errorf("%s is not a page number", value)
+	}
+
+	if pages != "" {
+		if progress.Pages, err = strconv.Atoi(strings.TrimSpace(pages)); err != nil {
+			return Progress{}, fmt.Errorf("%s is not a page count", value)
+		}
+	}
+
+	return progress, nil
+}
+
+func (p Progress) validate() error {
+	if p.Page == 0 && p.Percent == 0 {
+		return fmt.Errorf("progress needs either a page or a percentage")
+	}
+
+	if p.Page != 0 && p.Percent != 0 {
+		return fmt.Errorf("progress can't be both a page and a percentage")
+	}
+
+	if p.Page < 0 || p.Pages < 0 || p.Percent < 0 {
+		return fmt.Errorf("progress can't be negative")
+	}
+
+	if p.Percent > 100 {
+		return fmt.Errorf("%d%% is more than the whole book", p.Percent)
+	}
+
+	if p.Pages > 0 && p.Page > p.Pages {
+		return fmt.Errorf("page %d is past the end of the book, which has %d pages", p.Page, p.Pages)
+	}
+
+	return nil
+}
+
+type ReadingProgressed struct {
+	Isbn    string
+	Page    int
+	Pages   int
+	Percent int
+	When    time.Time
+}
+
+// RecordProgress notes how far through a book which is being read the reader is.  The
+// progress's Pages should be set from the catalogue where the book's length is known, so
+// that the page can be checked against it.
+func (l *Library) RecordProgress(isbn string, progress Progress, when time.Time) error {
+	if when.IsZero() {
+		when = time.Now()
+	}
+
+	if err := progress.validate(); err != nil {
+		return err
+	}
+
+	key, err := l.bookKey(isbn)
+	if err != nil {
+		return err
+	}
+
+	if _, found := l.reading[key]; !found {
+		return fmt.Errorf("%w: %s", ErrNotReading, isbn)
+	}
+
+	return goes.Apply(l.state, ReadingProgressed{
+		Isbn:    key,
+		Page:    progress.Page,
+		Pages:   progress.Pages,
+		Percent: progress.Percent,
+		When:    when,
+	})
+}
+
+func (l *Library) onReadingProgressed(e ReadingProgressed) {
+}
+
+type BookRated struct {
+	Isbn   string
+	Rating float64
+	When   time.Time
+}
+
+// ParseRating reads a number of stars, such as "4" or "3.5".
+func ParseRating(value string) (float64, error) {
+	rating, err := strconv.ParseFloat(strings.TrimSpace(value), 64)
+	if err != nil {
+		return 0, fmt.Errorf("%s is not a rating", value)
+	}
+
+	return rating, validateRating(rating)
+}
+
+// validateRating checks the rating is out of five stars, in steps of half a star, with 0
+// being no rating
+func validateRating(rating float64) error {
+	if rating < 0 || rating > 5 {
+		return fmt.Errorf("a rating must be between 0 and 5 stars, not %v", rating)
+	}
+	if rating*2 != math.Trunc(rating*2) {
+		return fmt.Errorf("a rating must be in whole or half stars, not %v", rating)
+	}
+
+	return nil
+}
+
+// RateBook gives the book a rating out of five stars, in half star steps.  Rating a book 0
+// removes its rating, and giving a book the rating it already has does nothing.
+func (l *Library) RateBook(isbn string, rating float64, when time.Time) error {
+	if when.IsZero() {
+		when = time.Now()
+	}
+
+	if err := validateRating(rating); err != nil {
+		return err
+	}
+
+	key, err := l.bookKey(isbn)
+	if err != nil {
+		return err
+	}
+
+	if l.ratings[key] == rating {
+		return nil
+	}
+
+	return goes.Apply(l.state, BookRated{
+		Isbn:   key,
+		Rating: rating,
+		When:   when,
+	})
+}
+
+func (l *Library) onBookRated(e BookRated) {
+	if e.Rating == 0 {
+		delete(l.ratings, e.Isbn)
+	} else {
+		l.ratings[e.Isbn] = e.Rating
+	}
+}
+
+// BookReviewed is a new or edited review, whose text is markdown
+type BookReviewed struct {
+	Isbn   string
+	Review string `goes:"personal"`
+	When   time.Time
+}
+
+// ReviewBook writes the book's review, replacing any review it already has.  The review
+// is markdown.
+func (l *Library) ReviewBook(isbn string, review string, when time.Time) error {
+	if when.IsZero() {
+		when = time.Now()
+	}
+
+	review = strings.TrimSpace(review)
+	if review == "" {
+		return fmt.Errorf("a review needs some text, or the review should be deleted instead")
+	}
+
+	key, err := l.bookKey(isbn)
+	if err != nil {
+		return err
+	}
+
+	return goes.Apply(l.state, BookReviewed{
+		Isbn:   key,
+		Review: review,
+		When:   when,
+	})
+}
+
+func (l *Library) onBookReviewed(e BookReviewed) {
+	l.reviewed[e.Isbn] = true
+}
+
+type BookReviewDeleted struct {
+	Isbn string
+	When time.Time
+}
+
+// DeleteReview removes the book's review.  Deleting a review which doesn't exist does
+// nothing.
+func (l *Library) DeleteReview(isbn string, when time.Time) error {
+	if when.IsZero() {
+		when = time.Now()
+	}
+
+	key, err := l.bookKey(isbn)
+	if err != nil {
+		return err
+	}
+
+	if !l.reviewed[key] {
+		return nil
+	}
+
+	return goes.Apply(l.state, BookReviewDeleted{
+		Isbn: key,
+		When: when,
+	})
+}
+
+func (l *Library) onBookReviewDeleted(e BookReviewDeleted) {
+	delete(l.reviewed, e.Isbn)
 }
@@ -0,0 +1,296 @@
+package domain
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"kirjasto/goes"
+	"slices"
+	"time"
+)
+
+// BookRow is one book in the library, stored as a table row so that the library
+// can be filtered and paged in sqlite rather than in memory.  Progress is the percentage
+// of the book which has been read, when it is known, and Rating is out of five stars.
+type BookRow struct {
+	Key      string    `db:"book_key,key"`
+	Isbn     string    `db:"isbn,index"`
+	Title    string    `db:"title,index"`
+	Author   string    `db:"author,index"`
+	State    string    `db:"state,index"`
+	Progress int       `db:"progress"`
+	Rating   float64   `db:"rating"`
+	Added    time.Time `db:"added,index"`
+	Tags     []string  `db:"tags"`
+}
+
+type LibraryBooksProjection struct {
+	*goes.TableProjection[BookRow]
+}
+
+func NewLibraryBooksProjection() *LibraryBooksProjection {
+	table, err := goes.NewTableProjection[BookRow]("library_books")
+	if err != nil {
+		// the schema comes from BookRow's tags, so this can only happen if they are wrong
+		panic(err)
+	}
+
+	projection := &LibraryBooksProjection{
+		TableProjection: table,
+	}
+
+	goes.AddTableHandler(projection.TableProjection, projection.onLibraryCreated)
+	goes.AddTableHandler(projection.TableProjection, projection.onBookImported)
+	goes.AddTableHandler(projection.TableProjection, projection.onBookAdded)
+	goes.AddTableHandler(projection.TableProjection, projection.onBookStarted)
+	goes.AddTableHandler(projection.TableProjection, projection.onBookFinished)
+	goes.AddTableHandler(projection.TableProjection, projection.onReadingProgressed)
+	goes.AddTableHandler(projection.TableProjection, projection.onBookRated)
+	goes.AddTableHandler(projection.TableProjection, projection.onBookReviewed)
+	goes.AddTableHandler(projection.TableProjection, projection.onBookReviewDeleted)
+	goes.AddTableHandler(projection.TableProjection, projection.onBookTagged)
+	goes.AddTableHandler(projection.TableProjection, projection.onBookUntagged)
+	goes.AddTableHandler(projection.TableProjection, projection.onTagRenamed)
+	goes.AddTableHandler(projection.TableProjection, projection.onTagsMerged)
+	goes.AddTableHandler(projection.TableProjection, projection.onBookRemoved)
+
+	return projection
+}
+
+func (p *LibraryBooksProjection) Version() int {
+	return 7
+}
+
+// bookKey identifies a book by its first isbn, falling back to the title for books
+// which were added without one.
+func bookKey(info BookInfo) string {
+	if len(info.Isbns) > 0 {
+		return info.Isbns[0]
+	}
+	return "title:" + info.Title
+}
+
+func newBookRow(info BookInfo) BookRow {
+	row := BookRow{
+		Key:    bookKey(info),
+		Title:  info.Title,
+		Author: info.Author,
+		State:  StateUnread,
+	}
+
+	if len(info.Isbns) > 0 {
+		row.Isbn = info.Isbns[0]
+	}
+
+	return row
+}
+
+func (p *LibraryBooksProjection) onLibraryCreated(ctx context.Context, table *goes.TableProjection[BookRow], event LibraryCreated) error {
+	return nil
+}
+
+func (p *LibraryBooksProjection) onBookAdded(ctx context.Context, table *goes.TableProjection[BookRow], event BookAdded) error {
+	row := newBookRow(event.Book)
+	row.Added = event.DateAdded
+	row.Tags = cleanTags(event.Tags)
+
+	return table.Upsert(ctx, row)
+}
+
+func (p *LibraryBooksProjection) onBookImported(ctx context.Context, table *goes.TableProjection[BookRow], event BookImported) error {
+	row := newBookRow(event.Book)
+	row.Added = event.DateAdded
+	row.Tags = cleanTags(event.Tags)
+	row.Rating = float64(event.Rating)
+
+	if !event.DateRead.IsZero() {
+		row.State = StateRead
+		row.Progress = 100
+	}
+
+	return table.Upsert(ctx, row)
+}
+
+func (p *LibraryBooksProjection) onBookStarted(ctx context.Context, table *goes.TableProjection[BookRow], event BookStarted) error {
+	return p.update(ctx, table, event.Isbn, func(row *BookRow) {
+		row.State = StateReading
+		row.Progress = 0
+	})
+}
+
+func (p *LibraryBooksProjection) onBookFinished(ctx context.Context, table *goes.TableProjection[BookRow], event BookFinished) error {
+	return p.update(ctx, table, event.Isbn, func(row *BookRow) {
+		row.State = StateRead
+		row.Progress = 100
+	})
+}
+
+func (p *LibraryBooksProjection) onReadingProgressed(ctx context.Context, table *goes.TableProjection[BookRow], event ReadingProgressed) error {
+	update := ProgressUpdate{Page: event.Page, Pages: event.Pages, Percent: event.Percent}
+
+	return p.update(ctx, table, event.Isbn, func(row *BookRow) {
+		row.Progress = update.PercentDone()
+	})
+}
+
+func (p *LibraryBooksProjection) onBookRated(ctx context.Context, table *goes.TableProjection[BookRow], event BookRated) error {
+	return p.update(ctx, table, event.Isbn, func(row *BookRow) {
+		row.Rating = event.Rating
+	})
+}
+
+// reviews aren't part of the table, only the LibraryView
+func (p *LibraryBooksProjection) onBookReviewed(ctx context.Context, table *goes.TableProjection[BookRow], event BookReviewed) error {
+	return nil
+}
+
+func (p *LibraryBooksProjection) onBookReviewDeleted(ctx context.Context, table *goes.TableProjection[BookRow], event BookReviewDeleted) error {
+	return nil
+}
+
+func (p *LibraryBooksProjection) onBookTagged(ctx context.Context, table *goes.TableProjection[BookRow], event BookTagged) error {
+	return p.update(ctx, table, event.Isbn, func(row *BookRow) {
+		row.Tags = append(row.Tags, event.Tags...)
+	})
+}
+
+func (p *LibraryBooksProjection) onBookUntagged(ctx context.Context, table *goes.TableProjection[BookRow], event BookUntagged) error {
+	return p.update(ctx, table, event.Isbn, func(row *BookRow) {
+		row.Tags = withoutTags(row.Tags, event.Tags)
+	})
+}
+
+func (p *LibraryBooksProjection) onTagRenamed(ctx context.Context, table *goes.TableProjection[BookRow], event TagRenamed) error {
+	return p.retag(ctx, table, []string{event.From}, event.To)
+}
+
+func (p *LibraryBooksProjection) onTagsMerged(ctx context.Context, table *goes.TableProjection[BookRow], event TagsMerged) error {
+	return p.retag(ctx, table, event.Tags, event.Into)
+}
+
+func (p *LibraryBooksProjection) onBookRemoved(ctx context.Context, table *goes.TableProjection[BookRow], event BookRemoved) error {
+	return table.Delete(ctx, event.Isbn)
+}
+
+// retag replaces the from tags with into, on every row which has any of them
+func (p *LibraryBooksProjection) retag(ctx context.Context, table *goes.TableProjection[BookRow], from []string, into string) error {
+	tags, err := json.Marshal(from)
+	if err != nil {
+		return err
+	}
+
+	rows, err := table.Query(ctx, table.Tx, "where exists (select 1 from json_each(tags) where value in (select value from json_each(@tags)))", sql.Named("tags", string(tags)))
+	if err != nil {
+		return err
+	}
+
+	for _, row := range rows {
+		row.Tags = mergeTags(row.Tags, from, into)
+		if err := table.Upsert(ctx, *row); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (p *LibraryBooksProjection) update(ctx context.Context, table *goes.TableProjection[BookRow], key string, change func(row *BookRow)) error {
+	row, err := table.Get(ctx, key)
+	if err != nil {
+		return err
+	}
+	if row == nil {
+		return fmt.Errorf("%w: %s", ErrUnknownBook, key)
+	}
+
+	change(row)
+	return table.Upsert(ctx, *row)
+}
+
+type BookFilter struct {
+	State  string
+	Limit  int
+	Offset int
+}
+
+// Books reads the library's books, most recently added first
+func (p *LibraryBooksProjection) Books(ctx context.Context, reader goes.Queryable, filter BookFilter) ([]*BookRow, error) {
+	clause := "where (@state = '' or state = @state) order by added desc limit @limit offset @offset"
+
+	limit := filter.Limit
+	if limit <= 0 {
+		limit = -1
+	}
+
+	return p.Query(ctx, reader, clause,
+		sql.Named("state", filter.State),
+		sql.Named("limit", limit),
+		sql.Named("offset", filter.Offset),
+	)
+}
+
+// CountByState returns how many books there are in each state
+func (p *LibraryBooksProjection) CountByState(ctx context.Context, reader goes.Queryable) (map[string]int, error) {
+	rows, err := reader.QueryContext(ctx, "select state, count(*) from library_books group by state")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	counts := map[string]int{}
+	for rows.Next() {
+		var state string
+		var count int
+		if err := rows.Scan(&state, &count); err != nil {
+			return nil, err
+		}
+		counts[state] = count
+	}
+
+	return counts, rows.Err()
+}
+
+// BookRowsOf converts a LibraryView into rows, applying the filter in memory in the same
+// way as Books does, along with the number of books in each state.
+func BookRowsOf(view *LibraryView, filter BookFilter) ([]*BookRow, map[string]int) {
+	counts := map[string]int{}
+	rows := make([]*BookRow, 0, len(view.Books))
+
+	for _, entry := range view.Books {
+		counts[entry.State]++
+
+		if filter.State != "" && entry.State != filter.State {
+			continue
+		}
+
+		row := &BookRow{
+			Key:      entry.Key,
+			Title:    entry.Title,
+			State:    entry.State,
+			Progress: entry.PercentRead(),
+			Rating:   entry.Rating,
+			Added:    entry.Added,
+			Tags:     entry.Tags,
+		}
+		if len(entry.Authors) > 0 {
+			row.Author = entry.Authors[0].Name
+		}
+		if len(entry.Isbns) > 0 {
+			row.Isbn = entry.Isbns[0]
+		}
+
+		rows = append(rows, row)
+	}
+
+	slices.SortStableFunc(rows, func(a, b *BookRow) int {
+		return b.Added.Compare(a.Added)
+	})
+
+	rows = rows[min(filter.Offset, len(rows)):]
+	if filter.Limit > 0 {
+		rows = rows[:min(filter.Limit, len(rows))]
+	}
+
+	return rows, counts
+}
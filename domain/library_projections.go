@@ -2,6 +2,7 @@ package domain
 
 import (
 	"context"
+	"fmt"
 	"kirjasto/goes"
 	"kirjasto/openlibrary"
 	"slices"
@@ -11,18 +12,185 @@ import (
 
 type LibraryView struct {
 	Books []*LibraryEntry
+
+	// History is the books which have been removed from the library, oldest first
+	History []*RemovedBook
 }
 
+// RemovedBook is a book which has left the library, and why
+type RemovedBook struct {
+	*LibraryEntry
+
+	Reason  string
+	Removed time.Time
+}
+
+const (
+	StateUnread  = "unread"
+	StateReading = "reading"
+	StateRead    = "read"
+)
+
 type LibraryEntry struct {
 	*openlibrary.Book
 
-	Added time.Time
-	Tags  []string
-	State string
+	// Key is the first isbn the book was added with, which reading events refer to it by
+	Key string
+
+	Added    time.Time
+	Tags     []string
+	State    string
+	Sessions []ReadingSession
+
+	// Rating is out of five stars in half star steps, with 0 being unrated
+	Rating    float64
+	ReadCount int
+	Review    *Review
 
 	KnownBook bool
 }
 
+// Review is the markdown text of a book's review
+type Review struct {
+	Text    string
+	Written time.Time
+	Edited  time.Time
+}
+
+// ReadingSession is one read of a book, which has a zero Finished time while the book is
+// still being read.
+type ReadingSession struct {
+	Started  time.Time
+	Finished time.Time
+	Progress []ProgressUpdate
+}
+
+func (s ReadingSession) Duration() time.Duration {
+	if s.Finished.IsZero() {
+		return 0
+	}
+	return s.Finished.Sub(s.Started)
+}
+
+type ProgressUpdate struct {
+	When    time.Time
+	Page    int
+	Pages   int
+	Percent int
+}
+
+// PercentDone is how far through the book the update is, which is 0 for a page of a
+// book whose length isn't known.
+func (u ProgressUpdate) PercentDone() int {
+	if u.Percent > 0 {
+		return u.Percent
+	}
+	if u.Pages > 0 {
+		return u.Page * 100 / u.Pages
+	}
+	return 0
+}
+
+// CurrentProgress is the latest update of the book being read, or nil if there isn't one
+func (e *LibraryEntry) CurrentProgress() *ProgressUpdate {
+	if e.State != StateReading || len(e.Sessions) == 0 {
+		return nil
+	}
+
+	updates := e.Sessions[len(e.Sessions)-1].Progress
+	if len(updates) == 0 {
+		return nil
+	}
+
+	return &updates[len(updates)-1]
+}
+
+// PercentRead is how far through the book the reader is, counting a read book as done
+func (e *LibraryEntry) PercentRead() int {
+	if e.State == StateRead {
+		return 100
+	}
+
+	if current := e.CurrentProgress(); current != nil {
+		return current.PercentDone()
+	}
+
+	return 0
+}
+
+// BooksInState filters the books by their state, with an empty state or "all" meaning
+// every book
+func (v *LibraryView) BooksInState(state string) []*LibraryEntry {
+	if state == "" || state == "all" {
+		return v.Books
+	}
+
+	books := []*LibraryEntry{}
+	for _, entry := range v.Books {
+		if entry.State == state {
+			books = append(books, entry)
+		}
+	}
+
+	return books
+}
+
+type TagCount struct {
+	Tag   string
+	Count int
+}
+
+// Tags counts the books with each tag, most used first
+func (v *LibraryView) Tags() []TagCount {
+	counts := map[string]int{}
+	for _, entry := range v.Books {
+		for _, tag := range entry.Tags {
+			counts[tag]++
+		}
+	}
+
+	tags := make([]TagCount, 0, len(counts))
+	for tag, count := range counts {
+		tags = append(tags, TagCount{Tag: tag, Count: count})
+	}
+
+	slices.SortFunc(tags, func(a, b TagCount) int {
+		if a.Count != b.Count {
+			return b.Count - a.Count
+		}
+		return strings.Compare(a.Tag, b.Tag)
+	})
+
+	return tags
+}
+
+// TaggedWith filters the books to those with the tag, with an empty tag meaning every book.
+func TaggedWith(books []*LibraryEntry, tag string) []*LibraryEntry {
+	if tag == "" {
+		return books
+	}
+
+	tagged := []*LibraryEntry{}
+	for _, entry := range books {
+		if slices.Contains(entry.Tags, tag) {
+			tagged = append(tagged, entry)
+		}
+	}
+
+	return tagged
+}
+
+// Entry finds a book by its key, the first isbn it was added with
+func (v *LibraryView) Entry(key string) (*LibraryEntry, error) {
+	for _, entry := range v.Books {
+		if entry.Key == key {
+			return entry, nil
+		}
+	}
+
+	return nil, fmt.Errorf("%w: %s", ErrUnknownBook, key)
+}
+
 type LibraryProjection struct {
 	*goes.SqlProjection[LibraryView]
 }
@@ -35,10 +203,27 @@ func NewLibraryProjection() *LibraryProjection {
 	goes.AddProjectionHandler(projection.SqlProjection, projection.onLibraryCreated)
 	goes.AddProjectionHandler(projection.SqlProjection, projection.onBookImported)
 	goes.AddProjectionHandler(projection.SqlProjection, projection.onBookAdded)
+	goes.AddProjectionHandler(projection.SqlProjection, projection.onBookStarted)
+	goes.AddProjectionHandler(projection.SqlProjection, projection.onBookFinished)
+	goes.AddProjectionHandler(projection.SqlProjection, projection.onReadingProgressed)
+	goes.AddProjectionHandler(projection.SqlProjection, projection.onBookRated)
+	goes.AddProjectionHandler(projection.SqlProjection, projection.onBookReviewed)
+	goes.AddProjectionHandler(projection.SqlProjection, projection.onBookReviewDeleted)
+	goes.AddProjectionHandler(projection.SqlProjection, projection.onBookTagged)
+	goes.AddProjectionHandler(projection.SqlProjection, projection.onBookUntagged)
+	goes.AddProjectionHandler(projection.SqlProjection, projection.onTagRenamed)
+	goes.AddProjectionHandler(projection.SqlProjection, projection.onTagsMerged)
+	goes.AddProjectionHandler(projection.SqlProjection, projection.onBookRemoved)
 
 	return projection
 }
 
+// Version needs incrementing whenever LibraryView or a handler changes, so that
+// existing views are rebuilt.
+func (p *LibraryProjection) Version() int {
+	return 7
+}
+
 func (p *LibraryProjection) onLibraryCreated(ctx context.Context, view *LibraryView, event LibraryCreated) error {
 	return nil
 }
@@ -49,7 +234,7 @@ func (p *LibraryProjection) onBookAdded(ctx context.Context, view *LibraryView,
 		return err
 	}
 
-	le.Tags = event.Tags
+	le.Tags = cleanTags(event.Tags)
 	le.Added = event.DateAdded
 
 	view.Books = append(view.Books, le)
@@ -63,17 +248,171 @@ func (p *LibraryProjection) onBookImported(ctx context.Context, view *LibraryVie
 	}
 
 	if !event.DateRead.IsZero() {
-		le.State = "read"
+		le.State = StateRead
 	}
 
-	le.Tags = event.Tags
+	le.Tags = cleanTags(event.Tags)
 	le.Added = event.DateAdded
+	le.Rating = float64(event.Rating)
+	le.ReadCount = event.ReadCount
+
+	if event.Review != "" {
+		written := event.DateRead
+		if written.IsZero() {
+			written = event.DateAdded
+		}
+		le.Review = &Review{Text: event.Review, Written: written}
+	}
 
 	view.Books = append(view.Books, le)
 
 	return nil
 }
 
+func (p *LibraryProjection) onBookStarted(ctx context.Context, view *LibraryView, event BookStarted) error {
+	le, err := view.Entry(event.Isbn)
+	if err != nil {
+		return err
+	}
+
+	le.State = StateReading
+	le.Sessions = append(le.Sessions, ReadingSession{Started: event.When})
+
+	return nil
+}
+
+func (p *LibraryProjection) onBookFinished(ctx context.Context, view *LibraryView, event BookFinished) error {
+	le, err := view.Entry(event.Isbn)
+	if err != nil {
+		return err
+	}
+
+	if len(le.Sessions) == 0 {
+		return fmt.Errorf("%w: %s", ErrNotReading, event.Isbn)
+	}
+
+	le.State = StateRead
+	le.ReadCount++
+	le.Sessions[len(le.Sessions)-1].Finished = event.When
+
+	return nil
+}
+
+func (p *LibraryProjection) onReadingProgressed(ctx context.Context, view *LibraryView, event ReadingProgressed) error {
+	le, err := view.Entry(event.Isbn)
+	if err != nil {
+		return err
+	}
+
+	if len(le.Sessions) == 0 {
+		return fmt.Errorf("%w: %s", ErrNotReading, event.Isbn)
+	}
+
+	session := &le.Sessions[len(le.Sessions)-1]
+	session.Progress = append(session.Progress, ProgressUpdate{
+		When:    event.When,
+		Page:    event.Page,
+		Pages:   event.Pages,
+		Percent: event.Percent,
+	})
+
+	return nil
+}
+
+func (p *LibraryProjection) onBookRated(ctx context.Context, view *LibraryView, event BookRated) error {
+	le, err := view.Entry(event.Isbn)
+	if err != nil {
+		return err
+	}
+
+	le.Rating = event.Rating
+
+	return nil
+}
+
+func (p *LibraryProjection) onBookReviewed(ctx context.Context, view *LibraryView, event BookReviewed) error {
+	le, err := view.Entry(event.Isbn)
+	if err != nil {
+		return err
+	}
+
+	if le.Review == nil {
+		le.Review = &Review{Written: event.When}
+	} else {
+		le.Review.Edited = event.When
+	}
+	le.Review.Text = event.Review
+
+	return nil
+}
+
+func (p *LibraryProjection) onBookReviewDeleted(ctx context.Context, view *LibraryView, event BookReviewDeleted) error {
+	le, err := view.Entry(event.Isbn)
+	if err != nil {
+		return err
+	}
+
+	le.Review = nil
+
+	return nil
+}
+
+func (p *LibraryProjection) onBookTagged(ctx context.Context, view *LibraryView, event BookTagged) error {
+	le, err := view.Entry(event.Isbn)
+	if err != nil {
+		return err
+	}
+
+	le.Tags = append(le.Tags, event.Tags...)
+
+	return nil
+}
+
+func (p *LibraryProjection) onBookUntagged(ctx context.Context, view *LibraryView, event BookUntagged) error {
+	le, err := view.Entry(event.Isbn)
+	if err != nil {
+		return err
+	}
+
+	le.Tags = withoutTags(le.Tags, event.Tags)
+
+	return nil
+}
+
+func (p *LibraryProjection) onTagRenamed(ctx context.Context, view *LibraryView, event TagRenamed) error {
+	for _, le := range view.Books {
+		le.Tags = mergeTags(le.Tags, []string{event.From}, event.To)
+	}
+
+	return nil
+}
+
+func (p *LibraryProjection) onTagsMerged(ctx context.Context, view *LibraryView, event TagsMerged) error {
+	for _, le := range view.Books {
+		le.Tags = mergeTags(le.Tags, event.Tags, event.Into)
+	}
+
+	return nil
+}
+
+func (p *LibraryProjection) onBookRemoved(ctx context.Context, view *LibraryView, event BookRemoved) error {
+	le, err := view.Entry(event.Isbn)
+	if err != nil {
+		return err
+	}
+
+	view.Books = slices.DeleteFunc(view.Books, func(entry *LibraryEntry) bool {
+		return entry == le
+	})
+	view.History = append(view.History, &RemovedBook{
+		LibraryEntry: le,
+		Reason:       event.Reason,
+		Removed:      event.When,
+	})
+
+	return nil
+}
+
 func (p *LibraryProjection) createLibraryEntry(ctx context.Context, info BookInfo) (*LibraryEntry, error) {
 	book, err := p.findBook(ctx, info)
 	if err != nil {
@@ -82,7 +421,8 @@ func (p *LibraryProjection) createLibraryEntry(ctx context.Context, info BookInf
 
 	le := &LibraryEntry{
 		Book:      book,
-		State:     "unread",
+		Key:       bookKey(info),
+		State:     StateUnread,
 		KnownBook: book != nil,
 	}
 
@@ -103,7 +443,8 @@ func (p *LibraryProjection) createLibraryEntry(ctx context.Context, info BookInf
 
 func (p *LibraryProjection) findBook(ctx context.Context, info BookInfo) (*openlibrary.Book, error) {
 
-	isbns := info.Isbns
+	// the isbns are the event's, which other projections also read
+	isbns := slices.Clone(info.Isbns)
 	// prefer longer isbns
 	slices.SortFunc(isbns, func(a, b string) int {
 		return len(b) - len(a)
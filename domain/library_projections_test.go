@@ -0,0 +1,238 @@
+package domain
+
+import (
+	"context"
+	"errors"
+	"kirjasto/goes"
+	"kirjasto/goes/goestest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLibraryProjectionUsesTheCatalogue(t *testing.T) {
+	db := goestest.NewDatabase(t)
+	seedCatalogue(t, db, catalogueEdition{Key: "OL1M", Title: "The Catalogued Book", Isbn: "9780000000001", Author: "Some Author"})
+
+	p := NewLibraryProjection()
+	added := time.Date(2024, 1, 2, 0, 0, 0, 0, time.UTC)
+
+	goestest.NewProjectionFixture(t, db, p.SqlProjection).
+		Given(LibraryID,
+			LibraryCreated{ID: LibraryID},
+			BookAdded{Book: BookInfo{Isbns: []string{"9780000000001"}, Title: "catalogued"}, Tags: []string{"fiction"}, DateAdded: added},
+			BookImported{Book: BookInfo{Title: "Not In The Catalogue", Author: "Someone Else"}, DateRead: added},
+		).
+		Then(func(t testing.TB, view *LibraryView) {
+			assert.Len(t, view.Books, 2)
+
+			known := view.Books[0]
+			assert.True(t, known.KnownBook)
+			assert.Equal(t, "The Catalogued Book", known.Title)
+			assert.Equal(t, "Some Author", known.Authors[0].Name)
+			assert.Equal(t, []string{"fiction"}, known.Tags)
+			assert.Equal(t, "unread", known.State)
+
+			unknown := view.Books[1]
+			assert.False(t, unknown.KnownBook)
+			assert.Equal(t, "Not In The Catalogue", unknown.Title)
+			assert.Equal(t, "read", unknown.State)
+		})
+}
+
+func TestLibraryProjectionReadingSessions(t *testing.T) {
+	db := goestest.NewDatabase(t)
+	seedCatalogue(t, db)
+
+	p := NewLibraryProjection()
+	first := time.Date(2024, 1, 2, 0, 0, 0, 0, time.UTC)
+	second := time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC)
+
+	fixture := goestest.NewProjectionFixture(t, db, p.SqlProjection).
+		Given(LibraryID,
+			LibraryCreated{ID: LibraryID},
+			BookAdded{Book: BookInfo{Isbns: []string{"0107717190"}, Title: "Reread"}},
+			BookStarted{Isbn: "0107717190", When: first},
+			BookFinished{Isbn: "0107717190", When: first.Add(72 * time.Hour)},
+			BookStarted{Isbn: "0107717190", When: second},
+		)
+
+	fixture.Then(func(t testing.TB, view *LibraryView) {
+		book := view.Books[0]
+		assert.Equal(t, StateReading, book.State)
+		assert.Len(t, book.Sessions, 2)
+		assert.Equal(t, 72*time.Hour, book.Sessions[0].Duration())
+		assert.True(t, book.Sessions[1].Finished.IsZero())
+	})
+}
+
+func TestLibraryProjectionReadingProgress(t *testing.T) {
+	db := goestest.NewDatabase(t)
+	seedCatalogue(t, db)
+
+	p := NewLibraryProjection()
+	started := time.Date(2024, 1, 2, 0, 0, 0, 0, time.UTC)
+
+	fixture := goestest.NewProjectionFixture(t, db, p.SqlProjection).
+		Given(LibraryID,
+			LibraryCreated{ID: LibraryID},
+			BookAdded{Book: BookInfo{Isbns: []string{"0107717190"}, Title: "Long"}},
+			BookStarted{Isbn: "0107717190", When: started},
+			ReadingProgressed{Isbn: "0107717190", Percent: 10, When: started.Add(24 * time.Hour)},
+			ReadingProgressed{Isbn: "0107717190", Page: 175, Pages: 350, When: started.Add(48 * time.Hour)},
+		)
+
+	fixture.Then(func(t testing.TB, view *LibraryView) {
+		book := view.Books[0]
+		assert.Len(t, book.Sessions[0].Progress, 2)
+		assert.Equal(t, 175, book.CurrentProgress().Page)
+		assert.Equal(t, 50, book.PercentRead())
+		assert.Len(t, view.BooksInState(StateReading), 1)
+		assert.Empty(t, view.BooksInState(StateRead))
+	})
+}
+
+func TestLibraryProjectionRatingsAndReviews(t *testing.T) {
+	db := goestest.NewDatabase(t)
+	seedCatalogue(t, db)
+
+	p := NewLibraryProjection()
+	added := time.Date(2024, 1, 2, 0, 0, 0, 0, time.UTC)
+	edited := time.Date(2024, 2, 1, 0, 0, 0, 0, time.UTC)
+
+	goestest.NewProjectionFixture(t, db, p.SqlProjection).
+		Given(LibraryID,
+			LibraryCreated{ID: LibraryID},
+			BookImported{Book: BookInfo{Isbns: []string{"0107717190"}, Title: "Imported"}, Rating: 4, ReadCount: 2, Review: "Fine", DateAdded: added},
+			BookAdded{Book: BookInfo{Isbns: []string{"9780000000002"}, Title: "Added"}},
+			BookRated{Isbn: "0107717190", Rating: 4.5},
+			BookReviewed{Isbn: "0107717190", Review: "Better on a reread", When: edited},
+			BookReviewed{Isbn: "9780000000002", Review: "Gone", When: edited},
+			BookReviewDeleted{Isbn: "9780000000002", When: edited},
+		).
+		Then(func(t testing.TB, view *LibraryView) {
+			imported := view.Books[0]
+			assert.Equal(t, 4.5, imported.Rating)
+			assert.Equal(t, 2, imported.ReadCount)
+			assert.Equal(t, &Review{Text: "Better on a reread", Written: added, Edited: edited}, imported.Review)
+
+			assert.Nil(t, view.Books[1].Review)
+		})
+}
+
+func TestLibraryProjectionsRetagEveryBook(t *testing.T) {
+	db := goestest.NewDatabase(t)
+	seedCatalogue(t, db)
+
+	ctx := context.Background()
+	store := goes.NewSqliteStore(db)
+	assert.NoError(t, RegisterProjections(store))
+	assert.NoError(t, store.Initialise(ctx))
+
+	err := UpdateLibrary(ctx, store, LibraryID, func(l *Library) error {
+		return errors.Join(
+			l.AddBook(BookInfo{Isbns: []string{"0107717190"}, Title: "First"}, []string{"scifi", "owned"}),
+			l.AddBook(BookInfo{Isbns: []string{"9780000000002"}, Title: "Second"}, []string{"sf"}),
+			l.AddBook(BookInfo{Isbns: []string{"9780000000003"}, Title: "Third"}, []string{"owned"}),
+			l.TagBook("9780000000003", "sci-fi"),
+			l.UntagBook("0107717190", "owned"),
+			l.MergeTags("sci-fi", "scifi", "sf"),
+			l.RenameTag("owned", "shelved"),
+		)
+	})
+	assert.NoError(t, err)
+
+	// the library's view is async, so is only updated by the projector
+	_, err = store.CatchUp(ctx, goes.DefaultBatchSize)
+	assert.NoError(t, err)
+
+	view, err := NewLibraryProjection().View(ctx, db, LibraryID)
+	assert.NoError(t, err)
+	assert.Equal(t, []TagCount{{Tag: "sci-fi", Count: 3}, {Tag: "shelved", Count: 1}}, view.Tags())
+	assert.Len(t, TaggedWith(view.Books, "shelved"), 1)
+
+	rows, err := NewLibraryBooksProjection().Query(ctx, db, "order by book_key")
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"sci-fi"}, rows[0].Tags)
+	assert.Equal(t, []string{"sci-fi"}, rows[1].Tags)
+	assert.Equal(t, []string{"shelved", "sci-fi"}, rows[2].Tags)
+}
+
+func TestLibraryProjectionsCleanImportedTags(t *testing.T) {
+	db := goestest.NewDatabase(t)
+	seedCatalogue(t, db)
+
+	ctx := context.Background()
+	store := goes.NewSqliteStore(db)
+	assert.NoError(t, RegisterProjections(store))
+	assert.NoError(t, store.Initialise(ctx))
+
+	err := UpdateLibrary(ctx, store, LibraryID, func(l *Library) error {
+		return errors.Join(
+			l.ImportBook(ImportData{Isbns: []string{"0107717190"}, Title: "Imported", Shelves: []string{" to-read", "", "to-read"}}),
+			l.AddBook(BookInfo{Isbns: []string{"9780000000002"}, Title: "Added"}, []string{"owned ", "owned"}),
+		)
+	})
+	assert.NoError(t, err)
+
+	_, err = store.CatchUp(ctx, goes.DefaultBatchSize)
+	assert.NoError(t, err)
+
+	view, err := NewLibraryProjection().View(ctx, db, LibraryID)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"to-read"}, view.Books[0].Tags)
+	assert.Equal(t, []string{"owned"}, view.Books[1].Tags)
+
+	rows, err := NewLibraryBooksProjection().Query(ctx, db, "order by book_key")
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"to-read"}, rows[0].Tags)
+	assert.Equal(t, []string{"owned"}, rows[1].Tags)
+}
+
+func TestLibraryProjectionsKeepRemovedBooksInTheHistory(t *testing.T) {
+	db := goestest.NewDatabase(t)
+	seedCatalogue(t, db)
+
+	ctx := context.Background()
+	store := goes.NewSqliteStore(db)
+	assert.NoError(t, RegisterProjections(store))
+	assert.NoError(t, store.Initialise(ctx))
+
+	removed := time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC)
+	book := BookInfo{Isbns: []string{"0107717190"}, Title: "Lent Out"}
+
+	err := UpdateLibrary(ctx, store, LibraryID, func(l *Library) error {
+		return errors.Join(
+			l.AddBook(book, nil),
+			l.AddBook(BookInfo{Isbns: []string{"9780000000002"}, Title: "Kept"}, nil),
+			l.RemoveBook("0107717190", RemovedLost, removed),
+			l.AddBook(book, nil),
+		)
+	})
+	assert.NoError(t, err)
+
+	_, err = store.CatchUp(ctx, goes.DefaultBatchSize)
+	assert.NoError(t, err)
+
+	view, err := NewLibraryProjection().View(ctx, db, LibraryID)
+	assert.NoError(t, err)
+	assert.Len(t, view.Books, 2)
+	assert.Len(t, view.History, 1)
+	assert.Equal(t, "Lent Out", view.History[0].Title)
+	assert.Equal(t, RemovedLost, view.History[0].Reason)
+	assert.Equal(t, removed, view.History[0].Removed)
+
+	rows, err := NewLibraryBooksProjection().Query(ctx, db, "")
+	assert.NoError(t, err)
+	assert.Len(t, rows, 2)
+
+	err = UpdateLibrary(ctx, store, LibraryID, func(l *Library) error {
+		return l.RemoveBook("9780000000002", RemovedDuplicate, removed)
+	})
+	assert.NoError(t, err)
+
+	rows, err = NewLibraryBooksProjection().Query(ctx, db, "")
+	assert.NoError(t, err)
+	assert.Len(t, rows, 1)
+}
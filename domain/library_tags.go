@@ -0,0 +1,198 @@
+package domain
+
+import (
+	"errors"
+	"fmt"
+	"kirjasto/goes"
+	"slices"
+	"strings"
+)
+
+var ErrUnknownTag = errors.New("no book has the tag")
+
+type BookTagged struct {
+	Isbn string
+	Tags []string
+}
+
+type BookUntagged struct {
+	Isbn string
+	Tags []string
+}
+
+// TagRenamed changes the tag on every book which has it
+type TagRenamed struct {
+	From string
+	To   string
+}
+
+// TagsMerged replaces each of the tags with Into, on every book which has them
+type TagsMerged struct {
+	Tags []string
+	Into string
+}
+
+// TagBook adds the tags to the book, ignoring any it already has.
+func (l *Library) TagBook(isbn string, tags ...string) error {
+	key, err := l.bookKey(isbn)
+	if err != nil {
+		return err
+	}
+
+	added := []string{}
+	for _, tag := range cleanTags(tags) {
+		if !slices.Contains(l.tags[key], tag) {
+			added = append(added, tag)
+		}
+	}
+
+	if len(added) == 0 {
+		return nil
+	}
+
+	return goes.Apply(l.state, BookTagged{
+		Isbn: key,
+		Tags: added,
+	})
+}
+
+func (l *Library) onBookTagged(e BookTagged) {
+	l.tags[e.Isbn] = append(l.tags[e.Isbn], e.Tags...)
+}
+
+// UntagBook removes the tags from the book, ignoring any it doesn't have.
+func (l *Library) UntagBook(isbn string, tags ...string) error {
+	key, err := l.bookKey(isbn)
+	if err != nil {
+		return err
+	}
+
+	removed := []string{}
+	for _, tag := range cleanTags(tags) {
+		if slices.Contains(l.tags[key], tag) {
+			removed = append(removed, tag)
+		}
+	}
+
+	if len(removed) == 0 {
+		return nil
+	}
+
+	return goes.Apply(l.state, BookUntagged{
+		Isbn: key,
+		Tags: removed,
+	})
+}
+
+func (l *Library) onBookUntagged(e BookUntagged) {
+	l.tags[e.Isbn] = withoutTags(l.tags[e.Isbn], e.Tags)
+}
+
+// RenameTag changes a tag on every book which has it.  Renaming a tag to one which is
+// already in use is an error, as that is merging the tags.
+func (l *Library) RenameTag(from string, to string) error {
+	from = strings.TrimSpace(from)
+	to = strings.TrimSpace(to)
+
+	if to == "" {
+		return fmt.Errorf("a tag can't be renamed to nothing")
+	}
+	if from == to {
+		return nil
+	}
+
+	if !l.hasTag(from) {
+		return fmt.Errorf("%w: %s", ErrUnknownTag, from)
+	}
+	if l.hasTag(to) {
+		return fmt.Errorf("%s is already a tag, so %s should be merged into it instead", to, from)
+	}
+
+	return goes.Apply(l.state, TagRenamed{
+		From: from,
+		To:   to,
+	})
+}
+
+func (l *Library) onTagRenamed(e TagRenamed) {
+	for key, tags := range l.tags {
+		l.tags[key] = mergeTags(tags, []string{e.From}, e.To)
+	}
+}
+
+// MergeTags replaces each of the tags with the into tag on every book, which doesn't need
+// to be in use already.
+func (l *Library) MergeTags(into string, tags ...string) error {
+	into = strings.TrimSpace(into)
+	if into == "" {
+		return fmt.Errorf("tags can't be merged into nothing")
+	}
+
+	merged := []string{}
+	for _, tag := range cleanTags(tags) {
+		if tag == into {
+			continue
+		}
+		if !l.hasTag(tag) {
+			return fmt.Errorf("%w: %s", ErrUnknownTag, tag)
+		}
+		merged = append(merged, tag)
+	}
+
+	if len(merged) == 0 {
+		return nil
+	}
+
+	return goes.Apply(l.state, TagsMerged{
+		Tags: merged,
+		Into: into,
+	})
+}
+
+func (l *Library) onTagsMerged(e TagsMerged) {
+	for key, tags := range l.tags {
+		l.tags[key] = mergeTags(tags, e.Tags, e.Into)
+	}
+}
+
+func (l *Library) hasTag(tag string) bool {
+	for _, tags := range l.tags {
+		if slices.Contains(tags, tag) {
+			return true
+		}
+	}
+	return false
+}
+
+// cleanTags trims the tags, and removes blanks and duplicates
+func cleanTags(tags []string) []string {
+	cleaned := make([]string, 0, len(tags))
+	for _, tag := range tags {
+		tag = strings.TrimSpace(tag)
+		if tag != "" && !slices.Contains(cleaned, tag) {
+			cleaned = append(cleaned, tag)
+		}
+	}
+	return cleaned
+}
+
+func withoutTags(tags []string, removed []string) []string {
+	return slices.DeleteFunc(slices.Clone(tags), func(tag string) bool {
+		return slices.Contains(removed, tag)
+	})
+}
+
+// mergeTags replaces any of the from tags with into, keeping the order of the tags and
+// not repeating into if the book already has it
+func mergeTags(tags []string, from []string, into string) []string {
+	merged := make([]string, 0, len(tags))
+	for _, tag := range tags {
+		if slices.Contains(from, tag) {
+			tag = into
+		}
+		if !slices.Contains(merged, tag) {
+			merged = append(merged, tag)
+		}
+	}
+	return merged
+}
@@ -1,8 +1,12 @@
 package domain
 
 import (
+	"context"
+	"kirjasto/goes"
+	"kirjasto/goes/goestest"
 	"slices"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
 )
@@ -24,3 +28,281 @@ func TestIsbnSorting(t *testing.T) {
 		"0107717204",
 	}, isbns)
 }
+
+func TestLibraryRoundTrip(t *testing.T) {
+	ctx := context.Background()
+	store := goes.NewMemoryStore(nil)
+
+	library := NewLibrary(LibraryID)
+	assert.NoError(t, library.AddBook(BookInfo{Isbns: []string{"9780107717193"}, Title: "Test"}, nil))
+	assert.NoError(t, SaveLibrary(ctx, store, library))
+
+	loaded, err := LoadLibrary(ctx, store, LibraryID)
+	assert.NoError(t, err)
+	assert.True(t, loaded.knownIsbns["9780107717193"])
+}
+
+func newLibraryFixture(t *testing.T, events ...any) *goestest.AggregateFixture[*Library] {
+	given := append([]any{LibraryCreated{ID: LibraryID}}, events...)
+	return goestest.NewAggregateFixture(t, LoadLibrary, SaveLibrary).Given(LibraryID, given...)
+}
+
+func TestAddingABook(t *testing.T) {
+	book := BookInfo{Isbns: []string{"9780107717193"}, Title: "Test"}
+
+	newLibraryFixture(t).
+		When(func(l *Library) error { return l.AddBook(book, []string{"fiction"}) }).
+		ThenEvents(func(t testing.TB, events []any) {
+			assert.Len(t, events, 1)
+
+			added := events[0].(BookAdded)
+			assert.Equal(t, book, added.Book)
+			assert.Equal(t, []string{"fiction"}, added.Tags)
+		})
+}
+
+func TestAddingAKnownBook(t *testing.T) {
+	book := BookInfo{Isbns: []string{"9780107717193"}, Title: "Test"}
+
+	newLibraryFixture(t, BookAdded{Book: book}).
+		When(func(l *Library) error { return l.AddBook(book, nil) }).
+		ThenNothing()
+}
+
+func TestImportingABook(t *testing.T) {
+	added := time.Date(2024, 1, 2, 0, 0, 0, 0, time.UTC)
+
+	newLibraryFixture(t).
+		When(func(l *Library) error {
+			return l.ImportBook(ImportData{Isbns: []string{"0107717190"}, Title: "Imported", Rating: 4, Shelves: []string{"read"}, DateAdded: added})
+		}).
+		Then(BookImported{
+			Book:      BookInfo{Isbns: []string{"0107717190"}, Title: "Imported"},
+			Tags:      []string{"read"},
+			Rating:    4,
+			DateAdded: added,
+		})
+}
+
+func TestImportingARowAgain(t *testing.T) {
+	imported := BookImported{ImportKey: "row-1", Book: BookInfo{Title: "No Isbn"}}
+
+	newLibraryFixture(t, imported).
+		When(func(l *Library) error {
+			return l.ImportBook(ImportData{ImportKey: "row-1", Title: "No Isbn"})
+		}).
+		ThenNothing()
+}
+
+func TestStartingABook(t *testing.T) {
+	started := time.Date(2024, 1, 2, 0, 0, 0, 0, time.UTC)
+
+	newLibraryFixture(t, BookAdded{Book: BookInfo{Isbns: []string{"0107717190"}}}).
+		When(func(l *Library) error { return l.StartReading("0107717190", started) }).
+		Then(BookStarted{Isbn: "0107717190", When: started})
+}
+
+func TestStartingAnUnknownBook(t *testing.T) {
+	newLibraryFixture(t).
+		When(func(l *Library) error { return l.StartReading("0107717190", time.Time{}) }).
+		ThenError(ErrUnknownBook)
+}
+
+func TestFinishingABook(t *testing.T) {
+	book := BookInfo{Isbns: []string{"0107717190", "9780107717193"}}
+	started := time.Date(2024, 1, 2, 0, 0, 0, 0, time.UTC)
+	finished := time.Date(2024, 1, 9, 0, 0, 0, 0, time.UTC)
+
+	// the book is finished by its other isbn, but the event uses the first
+	newLibraryFixture(t, BookAdded{Book: book}, BookStarted{Isbn: "0107717190", When: started}).
+		When(func(l *Library) error { return l.FinishReading("9780107717193", finished) }).
+		Then(BookFinished{Isbn: "0107717190", When: finished})
+}
+
+func TestFinishingABookWhichWasNotStarted(t *testing.T) {
+	newLibraryFixture(t, BookAdded{Book: BookInfo{Isbns: []string{"0107717190"}}}).
+		When(func(l *Library) error { return l.FinishReading("0107717190", time.Time{}) }).
+		ThenError(ErrNotReading)
+}
+
+func TestParsingProgress(t *testing.T) {
+	cases := map[string]Progress{
+		"120":       {Page: 120},
+		"120/350":   {Page: 120, Pages: 350},
+		" 45% ":     {Percent: 45},
+		"120 / 350": {Page: 120, Pages: 350},
+	}
+
+	for value, expected := range cases {
+		progress, err := ParseProgress(value)
+		assert.NoError(t, err, value)
+		assert.Equal(t, expected, progress, value)
+	}
+
+	for _, value := range []string{"", "page 3", "45.5%", "120/lots"} {
+		_, err := ParseProgress(value)
+		assert.Error(t, err, value)
+	}
+}
+
+func TestRecordingProgress(t *testing.T) {
+	started := time.Date(2024, 1, 2, 0, 0, 0, 0, time.UTC)
+	when := time.Date(2024, 1, 4, 0, 0, 0, 0, time.UTC)
+
+	newLibraryFixture(t, BookAdded{Book: BookInfo{Isbns: []string{"0107717190"}}}, BookStarted{Isbn: "0107717190", When: started}).
+		When(func(l *Library) error { return l.RecordProgress("0107717190", Progress{Page: 120, Pages: 350}, when) }).
+		Then(ReadingProgressed{Isbn: "0107717190", Page: 120, Pages: 350, When: when})
+}
+
+func TestRecordingProgressOfABookWhichWasNotStarted(t *testing.T) {
+	newLibraryFixture(t, BookAdded{Book: BookInfo{Isbns: []string{"0107717190"}}}).
+		When(func(l *Library) error { return l.RecordProgress("0107717190", Progress{Percent: 45}, time.Time{}) }).
+		ThenError(ErrNotReading)
+}
+
+func TestRecordingProgressPastTheEnd(t *testing.T) {
+	newLibraryFixture(t, BookAdded{Book: BookInfo{Isbns: []string{"0107717190"}}}, BookStarted{Isbn: "0107717190"}).
+		When(func(l *Library) error {
+			return l.RecordProgress("0107717190", Progress{Page: 400, Pages: 350}, time.Time{})
+		}).
+		ThenErrorContains("400")
+}
+
+func TestRatingABook(t *testing.T) {
+	when := time.Date(2024, 1, 2, 0, 0, 0, 0, time.UTC)
+
+	newLibraryFixture(t, BookAdded{Book: BookInfo{Isbns: []string{"0107717190"}}}).
+		When(func(l *Library) error { return l.RateBook("0107717190", 3.5, when) }).
+		Then(BookRated{Isbn: "0107717190", Rating: 3.5, When: when})
+}
+
+func TestRatingABookWithItsImportedRating(t *testing.T) {
+	newLibraryFixture(t, BookImported{Book: BookInfo{Isbns: []string{"0107717190"}}, Rating: 4}).
+		When(func(l *Library) error { return l.RateBook("0107717190", 4, time.Time{}) }).
+		ThenNothing()
+}
+
+func TestParsingRatings(t *testing.T) {
+	rating, err := ParseRating("4.5")
+	assert.NoError(t, err)
+	assert.Equal(t, 4.5, rating)
+
+	for _, value := range []string{"3.25", "6", "-1", "lots"} {
+		_, err := ParseRating(value)
+		assert.Error(t, err, value)
+	}
+}
+
+func TestEditingAReview(t *testing.T) {
+	when := time.Date(2024, 1, 2, 0, 0, 0, 0, time.UTC)
+
+	newLibraryFixture(t, BookAdded{Book: BookInfo{Isbns: []string{"0107717190"}}}, BookReviewed{Isbn: "0107717190", Review: "Good"}).
+		When(func(l *Library) error { return l.ReviewBook("0107717190", "  *Very* good\n", when) }).
+		Then(BookReviewed{Isbn: "0107717190", Review: "*Very* good", When: when})
+}
+
+func TestDeletingAReview(t *testing.T) {
+	when := time.Date(2024, 1, 2, 0, 0, 0, 0, time.UTC)
+
+	newLibraryFixture(t, BookImported{Book: BookInfo{Isbns: []string{"0107717190"}}, Review: "Imported"}).
+		When(func(l *Library) error { return l.DeleteReview("0107717190", when) }).
+		Then(BookReviewDeleted{Isbn: "0107717190", When: when})
+
+	newLibraryFixture(t, BookAdded{Book: BookInfo{Isbns: []string{"0107717190"}}}).
+		When(func(l *Library) error { return l.DeleteReview("0107717190", when) }).
+		ThenNothing()
+}
+
+func TestForgettingReviews(t *testing.T) {
+	ctx := context.Background()
+	store := goes.NewMemoryStore(nil)
+
+	library := NewLibrary(LibraryID)
+	assert.NoError(t, library.ImportBook(ImportData{Isbns: []string{"0107717190"}, Review: "Imported"}))
+	assert.NoError(t, library.ReviewBook("0107717190", "Edited", time.Now()))
+	assert.NoError(t, SaveLibrary(ctx, store, library))
+
+	assert.NoError(t, store.ForgetSubject(ctx, LibraryID.String()))
+
+	reviews := []string{}
+	for event, err := range store.Load(ctx, LibraryID, -1) {
+		assert.NoError(t, err)
+
+		switch e := event.Event.(type) {
+		case *BookImported:
+			reviews = append(reviews, e.Review)
+		case *BookReviewed:
+			reviews = append(reviews, e.Review)
+		}
+	}
+
+	assert.Equal(t, []string{goes.Redacted, goes.Redacted}, reviews)
+}
+
+func TestTaggingABook(t *testing.T) {
+	newLibraryFixture(t, BookAdded{Book: BookInfo{Isbns: []string{"0107717190"}}, Tags: []string{"fiction"}}).
+		When(func(l *Library) error { return l.TagBook("0107717190", "fiction", " sci-fi ", "") }).
+		Then(BookTagged{Isbn: "0107717190", Tags: []string{"sci-fi"}})
+}
+
+func TestUntaggingABook(t *testing.T) {
+	newLibraryFixture(t, BookAdded{Book: BookInfo{Isbns: []string{"0107717190"}}, Tags: []string{"fiction", "owned"}}).
+		When(func(l *Library) error { return l.UntagBook("0107717190", "owned", "missing") }).
+		Then(BookUntagged{Isbn: "0107717190", Tags: []string{"owned"}})
+}
+
+func TestRenamingATag(t *testing.T) {
+	given := []any{
+		BookAdded{Book: BookInfo{Isbns: []string{"0107717190"}}, Tags: []string{"scifi"}},
+		BookImported{Book: BookInfo{Title: "No Isbn"}, Tags: []string{"fantasy"}},
+	}
+
+	newLibraryFixture(t, given...).
+		When(func(l *Library) error { return l.RenameTag("scifi", "sci-fi") }).
+		Then(TagRenamed{From: "scifi", To: "sci-fi"})
+
+	newLibraryFixture(t, given...).
+		When(func(l *Library) error { return l.RenameTag("missing", "sci-fi") }).
+		ThenError(ErrUnknownTag)
+
+	// a book without an isbn still has its tags known
+	newLibraryFixture(t, given...).
+		When(func(l *Library) error { return l.RenameTag("scifi", "fantasy") }).
+		ThenErrorContains("merged")
+}
+
+func TestMergingTags(t *testing.T) {
+	newLibraryFixture(t, BookAdded{Book: BookInfo{Isbns: []string{"0107717190"}}, Tags: []string{"scifi", "sf"}}, TagRenamed{From: "sf", To: "SF"}).
+		When(func(l *Library) error { return l.MergeTags("sci-fi", "scifi", "SF", "sci-fi") }).
+		Then(TagsMerged{Tags: []string{"scifi", "SF"}, Into: "sci-fi"})
+}
+
+func TestRemovingABook(t *testing.T) {
+	when := time.Date(2024, 1, 2, 0, 0, 0, 0, time.UTC)
+	book := BookInfo{Isbns: []string{"0107717190", "9780107717193"}}
+
+	newLibraryFixture(t, BookAdded{Book: book}).
+		When(func(l *Library) error { return l.RemoveBook("9780107717193", RemovedGivenAway, when) }).
+		Then(BookRemoved{Isbn: "0107717190", Reason: RemovedGivenAway, When: when})
+
+	newLibraryFixture(t, BookAdded{Book: book}).
+		When(func(l *Library) error { return l.RemoveBook("0107717190", "burnt", when) }).
+		ThenErrorContains("burnt")
+}
+
+func TestReaddingARemovedBook(t *testing.T) {
+	book := BookInfo{Isbns: []string{"0107717190", "9780107717193"}, Title: "Again"}
+
+	newLibraryFixture(t, BookAdded{Book: book}, BookRemoved{Isbn: "0107717190", Reason: RemovedSold}).
+		When(func(l *Library) error {
+			return l.AddBook(BookInfo{Isbns: []string{"9780107717193"}, Title: "Again"}, nil)
+		}).
+		ThenEvents(func(t testing.TB, events []any) {
+			assert.Len(t, events, 1)
+			assert.IsType(t, BookAdded{}, events[0])
+		})
+
+	newLibraryFixture(t, BookAdded{Book: book}, BookRemoved{Isbn: "0107717190", Reason: RemovedSold}).
+		When(func(l *Library) error { return l.StartReading("0107717190", time.Time{}) }).
+		ThenError(ErrUnknownBook)
+}
@@ -0,0 +1,43 @@
+package domain
+
+import (
+	"context"
+	"kirjasto/goes"
+	"kirjasto/storage"
+)
+
+// RegisterProjections adds all of the domain's projections to the store
+func RegisterProjections(store goes.Store) error {
+	// the view looks up each book in the catalogue, which is too slow to do while saving,
+	// so it is kept up to date by the projector, and lags behind the events
+	if err := store.RegisterProjection("library_view", NewLibraryProjection(), goes.Async()); err != nil {
+		return err
+	}
+
+	// the books table is only used for listing, so a failure shouldn't stop the library changing
+	if err := store.RegisterProjection("library_books", NewLibraryBooksProjection(), goes.Isolate()); err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// OpenStore opens the event store in the database file for changing the library, with
+// the domain's projections registered and initialised.
+func OpenStore(ctx context.Context, databaseFile string, options ...goes.SqliteOption) (*goes.SqliteStore, error) {
+	writer, err := storage.Writer(ctx, databaseFile)
+	if err != nil {
+		return nil, err
+	}
+
+	store := goes.NewSqliteStore(writer, options...)
+	if err := RegisterProjections(store); err != nil {
+		return nil, err
+	}
+
+	if err := store.Initialise(ctx); err != nil {
+		return nil, err
+	}
+
+	return store, nil
+}
@@ -10,18 +10,28 @@ import (
 )
 
 type AggregateState struct {
-	id       uuid.UUID
-	sequence int
+	id            uuid.UUID
+	aggregateType string
+	sequence      int
 
 	handlers map[string]func(event any) error
 
 	pendingEvents []EventDescriptor
+
+	snapshotter      Snapshotter
+	snapshotPolicy   SnapshotPolicy
+	snapshotSequence int
 }
 
-func NewAggregateState() *AggregateState {
+// NewAggregateState creates the state for an aggregate of the given type.  The type is
+// saved with each event, and events are registered per type, so it must be unique to
+// the aggregate.
+func NewAggregateState(aggregateType string) *AggregateState {
 	return &AggregateState{
-		sequence: -1,
-		handlers: map[string]func(event any) error{},
+		aggregateType:    aggregateType,
+		sequence:         -1,
+		snapshotSequence: -1,
+		handlers:         map[string]func(event any) error{},
 	}
 }
 
@@ -29,9 +39,32 @@ func (a *AggregateState) ID() uuid.UUID {
 	return a.id
 }
 
-func Register[TEvent any](state *AggregateState, handler func(event TEvent)) {
+func (a *AggregateState) Type() string {
+	return a.aggregateType
+}
+
+type registerOptions struct {
+	version int
+}
+
+type RegisterOption func(o *registerOptions)
+
+// EventVersion sets the version new events of this type are saved with.  Older versions
+// are converted to the current version by any upcasters registered with RegisterUpcaster.
+func EventVersion(version int) RegisterOption {
+	return func(o *registerOptions) {
+		o.version = version
+	}
+}
+
+func Register[TEvent any](state *AggregateState, handler func(event TEvent), options ...RegisterOption) {
 	name := reflect.TypeOf(*new(TEvent)).Name()
 
+	opts := registerOptions{version: 1}
+	for _, option := range options {
+		option(&opts)
+	}
+
 	state.handlers[name] = func(event any) error {
 
 		switch e := event.(type) {
@@ -46,9 +79,7 @@ func Register[TEvent any](state *AggregateState, handler func(event TEvent)) {
 		return nil
 	}
 
-	eventFactory[name] = func() any {
-		return new(TEvent)
-	}
+	registerEvent[TEvent](state.aggregateType, name, opts.version)
 }
 
 func Apply[TEvent any](state *AggregateState, event TEvent) error {
@@ -63,19 +94,38 @@ func Apply[TEvent any](state *AggregateState, event TEvent) error {
 		return err
 	}
 
-	descriptor := EventDescriptor{
-		AggregateID: state.id,
-		Sequence:    state.sequence + len(state.pendingEvents) + 1,
-		Timestamp:   time.Now().UTC(),
-		EventType:   name,
-		Event:       event,
-	}
-
+	descriptor := newEventDescriptor(state.aggregateType, state.id, state.sequence+len(state.pendingEvents)+1, event)
 	state.pendingEvents = append(state.pendingEvents, descriptor)
 
 	return nil
 }
 
+// NewEventDescriptor describes an event for saving directly to a store, rather than
+// through an aggregate.  The event's type must already be registered, and the aggregate
+// type is the one which registered it.
+func NewEventDescriptor(aggregateID uuid.UUID, sequence int, event any) EventDescriptor {
+	aggregateType := ""
+	if types := aggregateTypesOf(reflect.TypeOf(event).Name()); len(types) == 1 {
+		aggregateType = types[0]
+	}
+
+	return newEventDescriptor(aggregateType, aggregateID, sequence, event)
+}
+
+func newEventDescriptor(aggregateType string, aggregateID uuid.UUID, sequence int, event any) EventDescriptor {
+	name := reflect.TypeOf(event).Name()
+
+	return EventDescriptor{
+		AggregateID:   aggregateID,
+		AggregateType: aggregateType,
+		Sequence:      sequence,
+		Timestamp:     time.Now().UTC(),
+		EventType:     name,
+		Version:       eventVersion(aggregateType, name),
+		Event:         event,
+	}
+}
+
 ///
 
 /// Save and Load
@@ -91,18 +141,49 @@ func Sequence(state *AggregateState) int {
 }
 
 type EventDescriptor struct {
-	AggregateID uuid.UUID
-	Sequence    int
-	Timestamp   time.Time
-	EventType   string
-	Event       any
+	// Position is the event's place in the store's global ordering, and is only
+	// populated for events which have been read from a store.
+	Position int64
+
+	AggregateID   uuid.UUID
+	AggregateType string
+	Sequence      int
+	Timestamp     time.Time
+	EventType     string
+	Version       int
+	Metadata      map[string]string
+	Event         any
+
+	// Hash links the event to the one saved before it, when the store has a hash chain
+	Hash string
 
 	marshalled []byte
+
+	// the event as it was stored, before any upcasting, for checking the hash
+	storedType     string
+	storedVersion  int
+	storedData     []byte
+	storedMetadata []byte
+
+	// the key for the event's personal fields, see DataSubject
+	keyID         string
+	encryptionKey []byte
 }
 
+// Marshal serialises the event, encrypting any personal fields when the descriptor
+// has been given an encryption key by the store.
 func (e *EventDescriptor) Marshal() ([]byte, error) {
 	if len(e.marshalled) == 0 {
-		content, err := json.Marshal(e.Event)
+		event := e.Event
+		if e.encryptionKey != nil {
+			encrypted, err := encryptEvent(event, e.encryptionKey)
+			if err != nil {
+				return nil, err
+			}
+			event = encrypted
+		}
+
+		content, err := json.Marshal(event)
 		if err != nil {
 			return nil, err
 		}
@@ -113,6 +194,17 @@ func (e *EventDescriptor) Marshal() ([]byte, error) {
 	return e.marshalled, nil
 }
 
+// MarshalRedacted serialises the event with its personal fields replaced by Redacted,
+// for sending it outside of the store.
+func (e *EventDescriptor) MarshalRedacted() ([]byte, error) {
+	event, err := redactEvent(e.Event)
+	if err != nil {
+		return nil, err
+	}
+
+	return json.Marshal(event)
+}
+
 func (a *AggregateState) ReplayEvent(event EventDescriptor) error {
 	handler, found := a.handlers[event.EventType]
 	if !found {
@@ -0,0 +1,106 @@
+package goes
+
+import (
+	"context"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/require"
+)
+
+// both aggregates have an event called "created", with different fields
+type shelf struct {
+	state  *AggregateState
+	name   string
+	create func(name string) error
+}
+
+type loan struct {
+	state    *AggregateState
+	borrower string
+	create   func(borrower string) error
+}
+
+func newShelf(id uuid.UUID) *shelf {
+	type created struct{ Name string }
+
+	s := &shelf{state: NewAggregateState("shelf")}
+	SetID(s.state, id)
+	Register(s.state, func(e created) { s.name = e.Name })
+	s.create = func(name string) error { return Apply(s.state, created{Name: name}) }
+
+	return s
+}
+
+func newLoan(id uuid.UUID) *loan {
+	type created struct{ Borrower string }
+
+	l := &loan{state: NewAggregateState("loan")}
+	SetID(l.state, id)
+	Register(l.state, func(e created) { l.borrower = e.Borrower }, EventVersion(2))
+	l.create = func(borrower string) error { return Apply(l.state, created{Borrower: borrower}) }
+
+	return l
+}
+
+func TestAggregateTypesHaveTheirOwnEvents(t *testing.T) {
+	ctx := context.Background()
+	store := NewSqliteStore(newTestDatabase(t))
+	require.NoError(t, store.Initialise(ctx))
+
+	shelfID := uuid.New()
+	loanID := uuid.New()
+
+	s := newShelf(shelfID)
+	require.NoError(t, s.create("Fiction"))
+	require.NoError(t, Save(ctx, store, s.state))
+
+	l := newLoan(loanID)
+	require.NoError(t, l.create("Someone"))
+	require.NoError(t, Save(ctx, store, l.state))
+
+	loadedShelf := newShelf(shelfID)
+	require.NoError(t, Load(ctx, store, loadedShelf.state))
+	require.Equal(t, "Fiction", loadedShelf.name)
+
+	loadedLoan := newLoan(loanID)
+	require.NoError(t, Load(ctx, store, loadedLoan.state))
+	require.Equal(t, "Someone", loadedLoan.borrower)
+
+	// a loan can't be loaded as a shelf
+	require.Error(t, Load(ctx, store, newShelf(loanID).state))
+
+	ids, err := store.AggregateIDs(ctx, "loan")
+	require.NoError(t, err)
+	require.Equal(t, []uuid.UUID{loanID}, ids)
+
+	events := []EventDescriptor{}
+	for event, err := range LoadType(ctx, store, "shelf") {
+		require.NoError(t, err)
+		events = append(events, event)
+	}
+	require.Len(t, events, 1)
+	require.Equal(t, "shelf", events[0].AggregateType)
+	require.Equal(t, shelfID, events[0].AggregateID)
+	require.Equal(t, 1, events[0].Version)
+}
+
+func TestMemoryStoreAggregateIDs(t *testing.T) {
+	ctx := context.Background()
+	store := NewMemoryStore(nil)
+
+	first := newCounter(uuid.New())
+	second := newCounter(uuid.New())
+	for _, c := range []*counter{first, second, first} {
+		require.NoError(t, Apply(c.state, counterIncremented{By: 1}))
+		require.NoError(t, Save(ctx, store, c.state))
+	}
+
+	ids, err := store.AggregateIDs(ctx, "counter")
+	require.NoError(t, err)
+	require.Equal(t, []uuid.UUID{first.state.ID(), second.state.ID()}, ids)
+
+	ids, err = store.AggregateIDs(ctx, "shelf")
+	require.NoError(t, err)
+	require.Empty(t, ids)
+}
@@ -0,0 +1,71 @@
+package goes
+
+import (
+	"context"
+	"database/sql"
+	"iter"
+)
+
+// the encryption key is joined so that reading an event doesn't need a second query,
+// which would deadlock on a single connection database
+const eventSelect = `
+	select event_id, aggregate_id, aggregate_type, sequence, timestamp, event_type, event_version, event_data, metadata, hash, events.key_id, encryption_keys.key_data
+	from events
+	left join encryption_keys on encryption_keys.key_id = events.key_id`
+
+// queryEvents runs a query which selects from the events table, using eventSelect
+// for the column list.
+func queryEvents(ctx context.Context, reader Queryable, query string, args ...any) iter.Seq2[EventDescriptor, error] {
+	return func(yield func(EventDescriptor, error) bool) {
+
+		rows, err := reader.QueryContext(ctx, query, args...)
+		if err != nil {
+			yield(EventDescriptor{}, err)
+			return
+		}
+		defer rows.Close()
+
+		for rows.Next() {
+			if !yield(scanEvent(rows)) {
+				return
+			}
+		}
+
+		if err := rows.Err(); err != nil {
+			yield(EventDescriptor{}, err)
+		}
+	}
+}
+
+func scanEvent(rows *sql.Rows) (EventDescriptor, error) {
+	e := EventDescriptor{}
+
+	var eventJson []byte
+	var metadataJson []byte
+	var hash sql.NullString
+	var keyID sql.NullString
+	var key []byte
+
+	if err := rows.Scan(&e.Position, &e.AggregateID, &e.AggregateType, &e.Sequence, &e.Timestamp, &e.EventType, &e.Version, &eventJson, &metadataJson, &hash, &keyID, &key); err != nil {
+		return e, err
+	}
+
+	e.Hash = hash.String
+	e.keyID = keyID.String
+	e.storedType = e.EventType
+	e.storedVersion = e.Version
+	e.storedData = eventJson
+	e.storedMetadata = metadataJson
+
+	metadata, err := unmarshalMetadata(metadataJson)
+	if err != nil {
+		return e, err
+	}
+	e.Metadata = metadata
+
+	if err := e.decode(eventJson, key); err != nil {
+		return e, err
+	}
+
+	return e, nil
+}
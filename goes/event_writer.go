@@ -7,36 +7,99 @@ import (
 
 type eventWriter struct {
 	*sql.Stmt
+	tx *sql.Tx
+
+	chained      bool
+	previousHash string
 }
 
-func newEventWriter(ctx context.Context, tx *sql.Tx) (*eventWriter, error) {
+// newEventWriter prepares to insert events.  Events are hashed when hashChain is set, or
+// when the chain has already been started, so that a store without the option can't
+// break the chain.
+func newEventWriter(ctx context.Context, tx *sql.Tx, hashChain bool) (*eventWriter, error) {
+
+	var lastHash sql.NullString
+	err := tx.QueryRowContext(ctx, "select hash from events order by event_id desc limit 1").Scan(&lastHash)
+	if err != nil && err != sql.ErrNoRows {
+		return nil, err
+	}
 
 	insertEvent, err := tx.PrepareContext(ctx, `
 insert into
 	events (
 			aggregate_id,
+			aggregate_type,
 			sequence,
 			timestamp,
 			event_type,
-			event_data
+			event_version,
+			event_data,
+			metadata,
+			hash,
+			key_id
 	)
-values (?, ?, ?, ?, ?)`)
+values (?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`)
 	if err != nil {
 		return nil, err
 	}
 
-	return &eventWriter{Stmt: insertEvent}, nil
+	return &eventWriter{
+		Stmt:         insertEvent,
+		tx:           tx,
+		chained:      hashChain || lastHash.Valid,
+		previousHash: lastHash.String,
+	}, nil
 }
 
-func (ew *eventWriter) Write(ctx context.Context, e EventDescriptor) error {
+// Write inserts the event, returning its position in the store.  Events which already
+// have a stored form, such as imported ones, are inserted as they are, keeping their
+// personal fields encrypted with their original key.
+func (ew *eventWriter) Write(ctx context.Context, e EventDescriptor) (int64, error) {
+
+	if e.storedData == nil {
+		if hasPersonalFields(e.Event) {
+			id, key, err := subjectKey(ctx, ew.tx, subjectOf(e))
+			if err != nil {
+				return 0, err
+			}
+
+			e.marshalled = nil
+			e.encryptionKey = key
+			e.keyID = id
+		}
+
+		eventJson, err := e.Marshal()
+		if err != nil {
+			return 0, err
+		}
+
+		e.storedType = e.EventType
+		e.storedVersion = e.Version
+		e.storedData = eventJson
+	}
+
+	metadataJson, err := marshalMetadata(e.Metadata)
+	if err != nil {
+		return 0, err
+	}
+	e.storedMetadata = metadataJson
 
-	eventJson, err := e.Marshal()
+	hash := sql.NullString{}
+	if ew.chained {
+		hash.String = chainHash(ew.previousHash, e)
+		hash.Valid = true
+	}
+
+	keyID := sql.NullString{String: e.keyID, Valid: e.keyID != ""}
+
+	result, err := ew.ExecContext(ctx, e.AggregateID, e.AggregateType, e.Sequence, e.Timestamp, e.storedType, e.storedVersion, e.storedData, metadataJson, hash, keyID)
 	if err != nil {
-		return err
+		return 0, err
 	}
 
-	if _, err := ew.ExecContext(ctx, e.AggregateID, e.Sequence, e.Timestamp, e.EventType, eventJson); err != nil {
-		return err
+	if ew.chained {
+		ew.previousHash = hash.String
 	}
-	return nil
+
+	return result.LastInsertId()
 }
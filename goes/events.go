@@ -3,20 +3,109 @@ package goes
 import (
 	"encoding/json"
 	"fmt"
+	"slices"
+	"strings"
+	"sync"
 )
 
-var eventFactory = map[string]func() any{}
+type eventRegistration struct {
+	factory func() any
+	version int
+}
+
+// eventRegistry holds the events of each aggregate type, so that two aggregates can
+// have events with the same name.  Events registered by projections, rather than by an
+// aggregate, are kept under the empty type.
+var eventRegistry = map[string]map[string]eventRegistration{}
+
+// registryLock guards eventRegistry, as aggregates and projections register their
+// events each time they are created, while other requests are reading events
+var registryLock sync.RWMutex
 
-func newEvent(eventType string) (any, error) {
-	if factory, found := eventFactory[eventType]; found {
-		return factory(), nil
+func registerEvent[TEvent any](aggregateType string, name string, version int) {
+	registryLock.Lock()
+	defer registryLock.Unlock()
+
+	if eventRegistry[aggregateType] == nil {
+		eventRegistry[aggregateType] = map[string]eventRegistration{}
 	}
 
-	return nil, fmt.Errorf("no factory for %s found", eventType)
+	eventRegistry[aggregateType][name] = eventRegistration{
+		factory: func() any { return new(TEvent) },
+		version: version,
+	}
 }
 
-func eventFromJson(eventType string, eventJson []byte) (any, error) {
-	event, err := newEvent(eventType)
+// lookupEvent finds the registration for an event of the aggregate type.  Events with no
+// type, which were saved before aggregates had one, use the only aggregate type which has
+// an event of that name, and then the registrations made by projections.
+func lookupEvent(aggregateType string, eventType string) (eventRegistration, error) {
+	registryLock.RLock()
+	defer registryLock.RUnlock()
+
+	if aggregateType != "" {
+		if registration, found := eventRegistry[aggregateType][eventType]; found {
+			return registration, nil
+		}
+	}
+
+	types := registeredTypesOf(eventType)
+	if len(types) > 1 {
+		return eventRegistration{}, fmt.Errorf("%s is registered by more than one aggregate type: %s", eventType, strings.Join(types, ", "))
+	}
+	if len(types) == 1 {
+		return eventRegistry[types[0]][eventType], nil
+	}
+
+	if registration, found := eventRegistry[""][eventType]; found {
+		return registration, nil
+	}
+
+	return eventRegistration{}, fmt.Errorf("no factory for %s found", eventType)
+}
+
+// aggregateTypesOf lists the aggregate types which have registered an event with the name
+func aggregateTypesOf(eventType string) []string {
+	registryLock.RLock()
+	defer registryLock.RUnlock()
+
+	return registeredTypesOf(eventType)
+}
+
+// registeredTypesOf is aggregateTypesOf for when registryLock is already held
+func registeredTypesOf(eventType string) []string {
+	types := []string{}
+	for aggregateType, events := range eventRegistry {
+		if _, found := events[eventType]; found && aggregateType != "" {
+			types = append(types, aggregateType)
+		}
+	}
+
+	slices.Sort(types)
+	return types
+}
+
+func newEvent(aggregateType string, eventType string) (any, error) {
+	registration, err := lookupEvent(aggregateType, eventType)
+	if err != nil {
+		return nil, err
+	}
+
+	return registration.factory(), nil
+}
+
+func eventVersion(aggregateType string, eventType string) int {
+	if registration, err := lookupEvent(aggregateType, eventType); err == nil {
+		return registration.version
+	}
+
+	return 1
+}
+
+// eventFromJson creates the event, decrypting its personal fields with the key.  When
+// there is no key, the personal fields are redacted.
+func eventFromJson(aggregateType string, eventType string, eventJson []byte, key []byte) (any, error) {
+	event, err := newEvent(aggregateType, eventType)
 	if err != nil {
 		return nil, err
 	}
@@ -25,5 +114,39 @@ func eventFromJson(eventType string, eventJson []byte) (any, error) {
 		return nil, err
 	}
 
+	if hasPersonalFields(event) {
+		if err := decryptEvent(event, key); err != nil {
+			return nil, err
+		}
+	}
+
 	return event, nil
 }
+
+// decode upcasts the stored form of the event to its current type and version, and
+// then unmarshals it into the descriptor, decrypting personal fields with the key.
+func (e *EventDescriptor) decode(eventJson []byte, key []byte) error {
+	raw, err := upcast(RawEvent{
+		EventType: e.EventType,
+		Version:   e.Version,
+		Data:      eventJson,
+	})
+	if err != nil {
+		return err
+	}
+
+	if current := eventVersion(e.AggregateType, raw.EventType); raw.Version > current {
+		return fmt.Errorf("%s is version %d, but the newest known version is %d", raw.EventType, raw.Version, current)
+	}
+
+	event, err := eventFromJson(e.AggregateType, raw.EventType, raw.Data, key)
+	if err != nil {
+		return err
+	}
+
+	e.EventType = raw.EventType
+	e.Version = raw.Version
+	e.Event = event
+
+	return nil
+}
@@ -0,0 +1,66 @@
+package goes
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/require"
+)
+
+func testEventQueries(t *testing.T, store Store) {
+	ctx := context.Background()
+	start := time.Now().UTC().Add(-time.Second)
+
+	c := newCounter(uuid.New())
+	for i := range 4 {
+		require.NoError(t, Apply(c.state, counterIncremented{By: i}))
+	}
+	require.NoError(t, Save(ctx, store, c.state))
+
+	other := newCounter(uuid.New())
+	require.NoError(t, Apply(other.state, counterIncremented{By: 1}))
+	require.NoError(t, Save(ctx, store, other.state))
+
+	min, max := 1, 2
+	filter := EventFilter{
+		AggregateIDs: []uuid.UUID{c.state.ID()},
+		Since:        start,
+		MinSequence:  &min,
+		MaxSequence:  &max,
+	}
+
+	sequences := []int{}
+	for event, err := range store.Events(ctx, filter) {
+		require.NoError(t, err)
+		sequences = append(sequences, event.Sequence)
+	}
+	require.Equal(t, []int{1, 2}, sequences)
+
+	for range store.Events(ctx, EventFilter{Until: start}) {
+		require.Fail(t, "no events were saved before the start")
+	}
+
+	last, err := store.LastPosition(ctx)
+	require.NoError(t, err)
+	require.Equal(t, int64(5), last)
+
+	event, err := store.Event(ctx, 3)
+	require.NoError(t, err)
+	require.Equal(t, &counterIncremented{By: 2}, event.Event)
+
+	_, err = store.Event(ctx, 10)
+	require.ErrorIs(t, err, ErrNoEvent)
+}
+
+func TestMemoryStoreEventQueries(t *testing.T) {
+	testEventQueries(t, NewMemoryStore(nil))
+}
+
+func TestSqliteStoreEventQueries(t *testing.T) {
+	store := NewSqliteStore(newTestDatabase(t))
+	require.NoError(t, store.Initialise(context.Background()))
+
+	testEventQueries(t, store)
+}
@@ -0,0 +1,69 @@
+package goes
+
+import (
+	"context"
+	"errors"
+	"kirjasto/tracing"
+
+	"go.opentelemetry.io/otel/attribute"
+)
+
+const DefaultRetries = 3
+
+type executeOptions struct {
+	retries int
+}
+
+type ExecuteOption func(o *executeOptions)
+
+// WithRetries sets how many more times the command is attempted after a concurrency conflict.
+func WithRetries(retries int) ExecuteOption {
+	return func(o *executeOptions) {
+		o.retries = retries
+	}
+}
+
+// Execute loads an aggregate, runs the command against it, and saves the result.  If
+// the save fails with an ErrConcurrencyConflict, the whole cycle is run again with a
+// freshly loaded aggregate, so the command must be safe to run more than once.
+func Execute[TAggregate any](
+	ctx context.Context,
+	store Store,
+	load func(ctx context.Context, store Store) (TAggregate, error),
+	save func(ctx context.Context, store Store, aggregate TAggregate) error,
+	command func(aggregate TAggregate) error,
+	options ...ExecuteOption,
+) error {
+	ctx, span := tr.Start(ctx, "execute")
+	defer span.End()
+
+	opts := executeOptions{retries: DefaultRetries}
+	for _, option := range options {
+		option(&opts)
+	}
+
+	for attempt := 0; ; attempt++ {
+		span.SetAttributes(attribute.Int("execute.attempts", attempt+1))
+
+		aggregate, err := load(ctx, store)
+		if err != nil {
+			return tracing.Error(span, err)
+		}
+
+		if err := command(aggregate); err != nil {
+			return tracing.Error(span, err)
+		}
+
+		err = save(ctx, store, aggregate)
+		if err == nil {
+			return nil
+		}
+
+		var conflict *ErrConcurrencyConflict
+		if !errors.As(err, &conflict) || attempt >= opts.retries {
+			return tracing.Error(span, err)
+		}
+
+		span.AddEvent("concurrency_conflict")
+	}
+}
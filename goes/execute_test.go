@@ -0,0 +1,89 @@
+package goes
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/require"
+)
+
+func TestConcurrencyConflictIsTyped(t *testing.T) {
+	ctx := context.Background()
+	store := NewMemoryStore(nil)
+	id := uuid.New()
+
+	first := newCounter(id)
+	second := newCounter(id)
+	require.NoError(t, Apply(first.state, counterIncremented{By: 1}))
+	require.NoError(t, Apply(second.state, counterIncremented{By: 1}))
+	require.NoError(t, Save(ctx, store, first.state))
+
+	var conflict *ErrConcurrencyConflict
+	require.True(t, errors.As(Save(ctx, store, second.state), &conflict))
+	require.Equal(t, id, conflict.AggregateID)
+	require.Equal(t, 0, conflict.StoredSequence)
+	require.Equal(t, -1, conflict.MemorySequence)
+}
+
+func TestExecuteRetriesConflicts(t *testing.T) {
+	ctx := context.Background()
+	store := NewMemoryStore(nil)
+	id := uuid.New()
+
+	load := func(ctx context.Context, store Store) (*counter, error) {
+		c := newCounter(id)
+		if err := Load(ctx, store, c.state); err != nil && err != ErrNotFound {
+			return nil, err
+		}
+		return c, nil
+	}
+	save := func(ctx context.Context, store Store, c *counter) error {
+		return Save(ctx, store, c.state)
+	}
+
+	attempts := 0
+	err := Execute(ctx, store, load, save, func(c *counter) error {
+		attempts++
+		if attempts == 1 {
+			// someone else writes while this command is running
+			other, _ := load(ctx, store)
+			Apply(other.state, counterIncremented{By: 10})
+			require.NoError(t, save(ctx, store, other))
+		}
+		return Apply(c.state, counterIncremented{By: 1})
+	})
+	require.NoError(t, err)
+	require.Equal(t, 2, attempts)
+
+	result, _ := load(ctx, store)
+	require.Equal(t, 11, result.total)
+}
+
+func TestExecuteGivesUpAfterRetries(t *testing.T) {
+	ctx := context.Background()
+	store := NewMemoryStore(nil)
+	id := uuid.New()
+
+	load := func(ctx context.Context, store Store) (*counter, error) {
+		return newCounter(id), nil
+	}
+	save := func(ctx context.Context, store Store, c *counter) error {
+		return Save(ctx, store, c.state)
+	}
+
+	other := newCounter(id)
+	require.NoError(t, Apply(other.state, counterIncremented{By: 1}))
+	require.NoError(t, Save(ctx, store, other.state))
+
+	attempts := 0
+	err := Execute(ctx, store, load, save, func(c *counter) error {
+		attempts++
+		return Apply(c.state, counterIncremented{By: 1})
+	}, WithRetries(2))
+
+	var conflict *ErrConcurrencyConflict
+	require.True(t, errors.As(err, &conflict))
+	require.Equal(t, 3, attempts)
+}
@@ -0,0 +1,198 @@
+package goes
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"kirjasto/tracing"
+	"time"
+
+	"github.com/google/uuid"
+	"go.opentelemetry.io/otel/attribute"
+)
+
+// ExportedEvent is the form of an event written to and read from an export, one per line.
+type ExportedEvent struct {
+	AggregateID   uuid.UUID         `json:"aggregate_id"`
+	AggregateType string            `json:"aggregate_type,omitempty"`
+	Sequence      int               `json:"sequence"`
+	Timestamp     time.Time         `json:"timestamp"`
+	EventType     string            `json:"event_type"`
+	Version       int               `json:"event_version"`
+	Data          json.RawMessage   `json:"event_data"`
+	Metadata      map[string]string `json:"metadata,omitempty"`
+
+	// KeyID is the key which the event's personal fields are encrypted with
+	KeyID string `json:"key_id,omitempty"`
+}
+
+// Export writes every event in the store to the writer as JSON lines, in the order they
+// were saved, returning how many events were written.  Events are written as they were
+// stored, before upcasting, and with their personal fields still encrypted, so that
+// forgetting a subject also makes their data unreadable in any export.
+func Export(ctx context.Context, store Store, w io.Writer) (int, error) {
+	ctx, span := tr.Start(ctx, "export")
+	defer span.End()
+
+	encoder := json.NewEncoder(w)
+
+	count := 0
+	for event, err := range store.AllEvents(ctx) {
+		if err != nil {
+			return count, tracing.Error(span, err)
+		}
+
+		exported := ExportedEvent{
+			AggregateID:   event.AggregateID,
+			AggregateType: event.AggregateType,
+			Sequence:      event.Sequence,
+			Timestamp:     event.Timestamp,
+			EventType:     event.storedType,
+			Version:       event.storedVersion,
+			Data:          event.storedData,
+			Metadata:      event.Metadata,
+			KeyID:         event.keyID,
+		}
+
+		if err := encoder.Encode(exported); err != nil {
+			return count, tracing.Error(span, err)
+		}
+
+		count++
+	}
+
+	span.SetAttributes(attribute.Int("event.count", count))
+	return count, nil
+}
+
+// Import reads events written by Export and saves them to the store, which runs the
+// store's projections as usual.  The whole file is checked before anything is saved: every
+// event type must be known, and each aggregate's sequences must carry on from the events
+// already in the store without any gaps.
+func Import(ctx context.Context, store Store, r io.Reader) (int, error) {
+	ctx, span := tr.Start(ctx, "import")
+	defer span.End()
+
+	events, err := readExport(r)
+	if err != nil {
+		return 0, tracing.Error(span, err)
+	}
+
+	if err := checkContinuity(ctx, store, events); err != nil {
+		return 0, tracing.Error(span, err)
+	}
+
+	// consecutive events for the same aggregate are saved together, which keeps the
+	// events in the same order as the export
+	for start := 0; start < len(events); {
+		end := start + 1
+		for end < len(events) && events[end].AggregateID == events[start].AggregateID {
+			end++
+		}
+
+		batch := events[start:end]
+		if _, err := store.Save(ctx, batch[0].AggregateID, batch[0].Sequence-1, batch); err != nil {
+			return start, tracing.Error(span, err)
+		}
+
+		start = end
+	}
+
+	span.SetAttributes(attribute.Int("event.count", len(events)))
+	return len(events), nil
+}
+
+func readExport(r io.Reader) ([]EventDescriptor, error) {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 0, 64*1024), 16*1024*1024)
+
+	events := []EventDescriptor{}
+	line := 0
+	for scanner.Scan() {
+		line++
+		if len(scanner.Bytes()) == 0 {
+			continue
+		}
+
+		exported := ExportedEvent{}
+		if err := json.Unmarshal(scanner.Bytes(), &exported); err != nil {
+			return nil, fmt.Errorf("line %d: %w", line, err)
+		}
+
+		event := EventDescriptor{
+			AggregateID:   exported.AggregateID,
+			AggregateType: exported.AggregateType,
+			Sequence:      exported.Sequence,
+			Timestamp:     exported.Timestamp,
+			EventType:     exported.EventType,
+			Version:       exported.Version,
+			Metadata:      exported.Metadata,
+		}
+
+		if event.Version == 0 {
+			event.Version = 1
+		}
+
+		storedType, storedVersion := event.EventType, event.Version
+
+		// decoding fails for event types which have not been registered.  Without the
+		// key, the projections see the personal fields as redacted.
+		if err := event.decode(exported.Data, nil); err != nil {
+			return nil, fmt.Errorf("line %d: %w", line, err)
+		}
+
+		// the event is saved in its exported form, keeping its personal fields encrypted
+		// with the original key.  Older exports held personal fields decrypted, and those
+		// are encrypted again when saved.
+		if exported.KeyID != "" || !hasPersonalFields(event.Event) {
+			event.storedType = storedType
+			event.storedVersion = storedVersion
+			event.storedData = exported.Data
+			event.keyID = exported.KeyID
+		}
+
+		events = append(events, event)
+	}
+
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	return events, nil
+}
+
+func checkContinuity(ctx context.Context, store Store, events []EventDescriptor) error {
+	last := map[uuid.UUID]int{}
+
+	for i, event := range events {
+		previous, found := last[event.AggregateID]
+		if !found {
+			stored, err := lastStoredSequence(ctx, store, event.AggregateID)
+			if err != nil {
+				return err
+			}
+			previous = stored
+		}
+
+		if event.Sequence != previous+1 {
+			return fmt.Errorf("event %d: aggregate %s has sequence %d, expected %d", i+1, event.AggregateID, event.Sequence, previous+1)
+		}
+
+		last[event.AggregateID] = event.Sequence
+	}
+
+	return nil
+}
+
+func lastStoredSequence(ctx context.Context, store Store, aggregateID uuid.UUID) (int, error) {
+	last := -1
+	for event, err := range store.Load(ctx, aggregateID, -1) {
+		if err != nil {
+			return 0, err
+		}
+		last = event.Sequence
+	}
+	return last, nil
+}
@@ -0,0 +1,122 @@
+package goes
+
+import (
+	"bytes"
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/require"
+)
+
+func TestExportImportRoundTrip(t *testing.T) {
+	ctx := context.Background()
+	source := NewMemoryStore(nil)
+	id := uuid.New()
+
+	c := newCounter(id)
+	require.NoError(t, Apply(c.state, counterIncremented{By: 2}))
+	require.NoError(t, Apply(c.state, counterIncremented{By: 3}))
+	require.NoError(t, Save(WithActor(ctx, "someone"), source, c.state))
+
+	exported := &bytes.Buffer{}
+	count, err := Export(ctx, source, exported)
+	require.NoError(t, err)
+	require.Equal(t, 2, count)
+
+	target := NewMemoryStore(nil)
+	count, err = Import(ctx, target, exported)
+	require.NoError(t, err)
+	require.Equal(t, 2, count)
+
+	loaded := newCounter(id)
+	require.NoError(t, Load(ctx, target, loaded.state))
+	require.Equal(t, 5, loaded.total)
+
+	for event, err := range target.Load(ctx, id, -1) {
+		require.NoError(t, err)
+		require.Equal(t, "someone", event.Metadata[MetadataActor])
+	}
+}
+
+func TestImportSequenceGap(t *testing.T) {
+	id := uuid.New().String()
+	file := strings.Join([]string{
+		`{"aggregate_id":"` + id + `","sequence":0,"timestamp":"2024-01-01T00:00:00Z","event_type":"counterIncremented","event_version":1,"event_data":{"By":1}}`,
+		`{"aggregate_id":"` + id + `","sequence":2,"timestamp":"2024-01-01T00:00:00Z","event_type":"counterIncremented","event_version":1,"event_data":{"By":1}}`,
+	}, "\n")
+
+	newCounter(uuid.New())
+	store := NewMemoryStore(nil)
+
+	_, err := Import(context.Background(), store, strings.NewReader(file))
+	require.ErrorContains(t, err, "expected 1")
+
+	for range store.AllEvents(context.Background()) {
+		require.Fail(t, "no events should have been imported")
+	}
+}
+
+func TestImportUnknownEventType(t *testing.T) {
+	file := `{"aggregate_id":"` + uuid.New().String() + `","sequence":0,"timestamp":"2024-01-01T00:00:00Z","event_type":"neverRegistered","event_version":1,"event_data":{}}`
+
+	_, err := Import(context.Background(), NewMemoryStore(nil), strings.NewReader(file))
+	require.ErrorContains(t, err, "line 1")
+}
+
+func TestExportKeepsPersonalFieldsEncrypted(t *testing.T) {
+	for name, create := range snapshotStores() {
+		t.Run(name, func(t *testing.T) {
+			ctx := context.Background()
+			source := create(t)
+			id := uuid.New()
+
+			l := newLending(id)
+			require.NoError(t, Apply(l.state, bookLent{Isbn: "123", Borrower: "alice", Note: "careful"}))
+			require.NoError(t, Save(ctx, source, l.state))
+
+			exported := &bytes.Buffer{}
+			_, err := Export(ctx, source, exported)
+			require.NoError(t, err)
+			require.NotContains(t, exported.String(), "careful")
+			require.Contains(t, exported.String(), `"key_id"`)
+
+			target := create(t)
+			_, err = Import(ctx, target, bytes.NewReader(exported.Bytes()))
+			require.NoError(t, err)
+
+			// the target doesn't have the key, so can't read the personal fields
+			loaded := newLending(id)
+			require.NoError(t, Load(ctx, target, loaded.state))
+			require.Equal(t, bookLent{Isbn: "123", Borrower: Redacted, Note: Redacted}, loaded.lent[0])
+
+			reexported := &bytes.Buffer{}
+			_, err = Export(ctx, target, reexported)
+			require.NoError(t, err)
+			require.Equal(t, exported.String(), reexported.String())
+		})
+	}
+}
+
+func TestImportEncryptsDecryptedPersonalFields(t *testing.T) {
+	ctx := context.Background()
+	id := uuid.New()
+	newLending(id)
+
+	// exports used to hold personal fields decrypted
+	file := `{"aggregate_id":"` + id.String() + `","aggregate_type":"lending","sequence":0,"timestamp":"2024-01-01T00:00:00Z","event_type":"bookLent","event_version":1,"event_data":{"Isbn":"123","Borrower":"alice","Note":"careful"}}`
+
+	store := NewMemoryStore(nil)
+	_, err := Import(ctx, store, strings.NewReader(file))
+	require.NoError(t, err)
+
+	exported := &bytes.Buffer{}
+	_, err = Export(ctx, store, exported)
+	require.NoError(t, err)
+	require.NotContains(t, exported.String(), "careful")
+
+	loaded := newLending(id)
+	require.NoError(t, Load(ctx, store, loaded.state))
+	require.Equal(t, bookLent{Isbn: "123", Borrower: "alice", Note: "careful"}, loaded.lent[0])
+}
@@ -0,0 +1,118 @@
+package goestest
+
+import (
+	"context"
+	"kirjasto/goes"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/require"
+)
+
+// AggregateFixture runs a command against an aggregate loaded from the given events,
+// and checks the events it raises.  The aggregate is loaded and saved with the same
+// functions the application uses, against a memory store.
+type AggregateFixture[TAggregate any] struct {
+	t     testing.TB
+	load  func(ctx context.Context, store goes.Store, id uuid.UUID) (TAggregate, error)
+	save  func(ctx context.Context, store goes.Store, aggregate TAggregate) error
+	store *goes.MemoryStore
+
+	aggregateID  uuid.UUID
+	lastSequence int
+}
+
+func NewAggregateFixture[TAggregate any](
+	t testing.TB,
+	load func(ctx context.Context, store goes.Store, id uuid.UUID) (TAggregate, error),
+	save func(ctx context.Context, store goes.Store, aggregate TAggregate) error,
+) *AggregateFixture[TAggregate] {
+	return &AggregateFixture[TAggregate]{
+		t:            t,
+		load:         load,
+		save:         save,
+		store:        goes.NewMemoryStore(nil),
+		lastSequence: -1,
+	}
+}
+
+// Given sets the aggregate's history.  It needs at least one event, as the aggregate is
+// loaded from them.
+func (f *AggregateFixture[TAggregate]) Given(aggregateID uuid.UUID, events ...any) *AggregateFixture[TAggregate] {
+	f.t.Helper()
+
+	f.aggregateID = aggregateID
+	f.lastSequence = saveGiven(f.t, f.store, aggregateID, events)
+
+	return f
+}
+
+// When loads the aggregate, runs the command against it, and saves the aggregate if the
+// command succeeds.
+func (f *AggregateFixture[TAggregate]) When(command func(aggregate TAggregate) error) *AggregateResult {
+	f.t.Helper()
+	ctx := context.Background()
+
+	aggregate, err := f.load(ctx, f.store, f.aggregateID)
+	require.NoError(f.t, err, "loading the aggregate from the given events")
+
+	result := &AggregateResult{t: f.t}
+
+	if result.err = command(aggregate); result.err != nil {
+		return result
+	}
+
+	require.NoError(f.t, f.save(ctx, f.store, aggregate))
+
+	for event, err := range f.store.Load(ctx, f.aggregateID, f.lastSequence) {
+		require.NoError(f.t, err)
+		result.events = append(result.events, deref(event.Event))
+	}
+
+	return result
+}
+
+type AggregateResult struct {
+	t      testing.TB
+	events []any
+	err    error
+}
+
+// Then checks the command raised exactly these events, in order
+func (r *AggregateResult) Then(expected ...any) {
+	r.t.Helper()
+
+	require.NoError(r.t, r.err)
+	require.Equal(r.t, expected, r.events)
+}
+
+// ThenEvents passes the raised events to the assertion, for when they can't be
+// compared exactly, such as when they contain the current time
+func (r *AggregateResult) ThenEvents(assertion func(t testing.TB, events []any)) {
+	r.t.Helper()
+
+	require.NoError(r.t, r.err)
+	assertion(r.t, r.events)
+}
+
+// ThenNothing checks the command succeeded without raising any events
+func (r *AggregateResult) ThenNothing() {
+	r.t.Helper()
+
+	require.NoError(r.t, r.err)
+	require.Empty(r.t, r.events)
+}
+
+// ThenError checks the command failed with an error matching target, using errors.Is
+func (r *AggregateResult) ThenError(target error) {
+	r.t.Helper()
+
+	require.ErrorIs(r.t, r.err, target)
+}
+
+// ThenErrorContains checks the command failed with an error containing the text
+func (r *AggregateResult) ThenErrorContains(text string) {
+	r.t.Helper()
+
+	require.ErrorContains(r.t, r.err, text)
+}
@@ -0,0 +1,52 @@
+// Package goestest has fixtures for testing aggregates and projections in the style of
+// given events, when a command runs, then these events (or this error) happen.
+package goestest
+
+import (
+	"context"
+	"database/sql"
+	"kirjasto/goes"
+	"reflect"
+	"testing"
+
+	"github.com/google/uuid"
+	_ "github.com/mattn/go-sqlite3"
+	"github.com/stretchr/testify/require"
+)
+
+// NewDatabase opens an in-memory sqlite database, which is closed when the test ends.
+// It has a single connection, as each connection to :memory: is a separate database.
+func NewDatabase(t testing.TB) *sql.DB {
+	db, err := sql.Open("sqlite3", ":memory:")
+	require.NoError(t, err)
+	db.SetMaxOpenConns(1)
+	t.Cleanup(func() { db.Close() })
+
+	return db
+}
+
+// saveGiven writes the events to the store as the aggregate's history, returning the
+// sequence of the last one
+func saveGiven(t testing.TB, store goes.Store, aggregateID uuid.UUID, events []any) int {
+	descriptors := make([]goes.EventDescriptor, len(events))
+	for i, event := range events {
+		descriptors[i] = goes.NewEventDescriptor(aggregateID, i, event)
+	}
+
+	if len(descriptors) > 0 {
+		_, err := store.Save(context.Background(), aggregateID, -1, descriptors)
+		require.NoError(t, err)
+	}
+
+	return len(events) - 1
+}
+
+// deref makes events read from a store, which are pointers, comparable with the
+// values that tests are written with
+func deref(event any) any {
+	value := reflect.ValueOf(event)
+	if value.Kind() == reflect.Pointer && !value.IsNil() {
+		return value.Elem().Interface()
+	}
+	return event
+}
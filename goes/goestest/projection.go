@@ -0,0 +1,62 @@
+package goestest
+
+import (
+	"context"
+	"database/sql"
+	"kirjasto/goes"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/require"
+)
+
+// ProjectionFixture runs a projection over the given events, using a memory store with
+// an in-memory database, which can be seeded with any other tables the projection reads.
+type ProjectionFixture[TView any] struct {
+	t          testing.TB
+	db         *sql.DB
+	store      *goes.MemoryStore
+	projection *goes.SqlProjection[TView]
+
+	aggregateID uuid.UUID
+}
+
+func NewProjectionFixture[TView any](t testing.TB, db *sql.DB, projection *goes.SqlProjection[TView]) *ProjectionFixture[TView] {
+	store := goes.NewMemoryStore(db)
+	require.NoError(t, store.RegisterProjection("fixture", projection))
+
+	return &ProjectionFixture[TView]{
+		t:          t,
+		db:         db,
+		store:      store,
+		projection: projection,
+	}
+}
+
+// Given saves an aggregate's history, which runs the events through the projection.
+// Each aggregate can only be given once.
+func (f *ProjectionFixture[TView]) Given(aggregateID uuid.UUID, events ...any) *ProjectionFixture[TView] {
+	f.t.Helper()
+
+	f.aggregateID = aggregateID
+	saveGiven(f.t, f.store, aggregateID, events)
+
+	return f
+}
+
+// Then passes the view built for the last aggregate given to the assertion
+func (f *ProjectionFixture[TView]) Then(assertion func(t testing.TB, view *TView)) {
+	f.t.Helper()
+
+	f.ThenView(f.aggregateID, assertion)
+}
+
+// ThenView passes the view built for the aggregate to the assertion
+func (f *ProjectionFixture[TView]) ThenView(aggregateID uuid.UUID, assertion func(t testing.TB, view *TView)) {
+	f.t.Helper()
+
+	view, err := f.projection.View(context.Background(), f.db, aggregateID)
+	require.NoError(f.t, err)
+
+	assertion(f.t, view)
+}
@@ -0,0 +1,146 @@
+package goes
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+	"hash"
+	"kirjasto/tracing"
+
+	"github.com/google/uuid"
+	"go.opentelemetry.io/otel/attribute"
+)
+
+// WithHashChain makes the store hash every event it saves, with each hash covering the
+// previous event's hash, so that editing or deleting a stored event can be detected by
+// Verify.  Once the chain has been started, it is continued by all stores, with or
+// without this option.
+func WithHashChain() SqliteOption {
+	return func(s *SqliteStore) {
+		s.hashChain = true
+	}
+}
+
+// chainHash hashes the event as it was stored, so that upcasting an event when it is
+// read doesn't change its hash
+func chainHash(previous string, e EventDescriptor) string {
+	h := sha256.New()
+
+	writeField(h, []byte(previous))
+	writeField(h, []byte(e.AggregateID.String()))
+	writeField(h, []byte(e.AggregateType))
+	writeField(h, binary.BigEndian.AppendUint64(nil, uint64(e.Sequence)))
+	writeField(h, binary.BigEndian.AppendUint64(nil, uint64(e.Timestamp.UnixNano())))
+	writeField(h, []byte(e.storedType))
+	writeField(h, binary.BigEndian.AppendUint64(nil, uint64(e.storedVersion)))
+	writeField(h, e.storedData)
+	writeField(h, e.storedMetadata)
+	writeField(h, []byte(e.keyID))
+
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// writeField prefixes the value with its length, so that moving bytes between
+// fields changes the hash
+func writeField(h hash.Hash, value []byte) {
+	h.Write(binary.BigEndian.AppendUint64(nil, uint64(len(value))))
+	h.Write(value)
+}
+
+type VerifyProblem struct {
+	Position    int64
+	AggregateID uuid.UUID
+	Sequence    int
+	Problem     string
+}
+
+func (p VerifyProblem) String() string {
+	return fmt.Sprintf("event %d (aggregate %s, sequence %d): %s", p.Position, p.AggregateID, p.Sequence, p.Problem)
+}
+
+type VerifyReport struct {
+	Events  int
+	Chained int
+
+	// BrokenLink is the first event whose hash doesn't match, after which every
+	// hash in the chain is suspect
+	BrokenLink *VerifyProblem
+	Gaps       []VerifyProblem
+}
+
+func (r VerifyReport) Ok() bool {
+	return r.BrokenLink == nil && len(r.Gaps) == 0
+}
+
+// Verify walks every event, checking the hash chain and that each aggregate's sequences
+// have no gaps.  Events saved before the chain was started have no hash and are only
+// checked for gaps.
+func (s *SqliteStore) Verify(ctx context.Context) (VerifyReport, error) {
+	ctx, span := tr.Start(ctx, "verify")
+	defer span.End()
+
+	report := VerifyReport{}
+	sequences := map[uuid.UUID]int{}
+	previousHash := ""
+	chainStarted := false
+
+	for event, err := range s.allEvents(ctx, s.db) {
+		if err != nil {
+			return report, tracing.Error(span, err)
+		}
+
+		report.Events++
+
+		expected := 0
+		if last, found := sequences[event.AggregateID]; found {
+			expected = last + 1
+		}
+		if event.Sequence != expected {
+			report.Gaps = append(report.Gaps, problemAt(event, fmt.Sprintf("expected sequence %d", expected)))
+		}
+		sequences[event.AggregateID] = event.Sequence
+
+		if report.BrokenLink != nil {
+			continue
+		}
+
+		if event.Hash == "" {
+			if chainStarted {
+				report.BrokenLink = problemPointer(problemAt(event, "hash is missing"))
+			}
+			continue
+		}
+
+		chainStarted = true
+		report.Chained++
+
+		if hash := chainHash(previousHash, event); hash != event.Hash {
+			report.BrokenLink = problemPointer(problemAt(event, "hash does not match"))
+			continue
+		}
+
+		previousHash = event.Hash
+	}
+
+	span.SetAttributes(
+		attribute.Int("event.count", report.Events),
+		attribute.Bool("verify.ok", report.Ok()),
+	)
+
+	return report, nil
+}
+
+func problemAt(event EventDescriptor, problem string) VerifyProblem {
+	return VerifyProblem{
+		Position:    event.Position,
+		AggregateID: event.AggregateID,
+		Sequence:    event.Sequence,
+		Problem:     problem,
+	}
+}
+
+func problemPointer(p VerifyProblem) *VerifyProblem {
+	return &p
+}
@@ -0,0 +1,114 @@
+package goes
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/require"
+)
+
+func newChainedStore(t *testing.T) *SqliteStore {
+	ctx := context.Background()
+	store := NewSqliteStore(newTestDatabase(t), WithHashChain())
+	require.NoError(t, store.Initialise(ctx))
+
+	for range 2 {
+		c := newCounter(uuid.New())
+		for i := range 3 {
+			require.NoError(t, Apply(c.state, counterIncremented{By: i}))
+		}
+		require.NoError(t, Save(ctx, store, c.state))
+	}
+
+	return store
+}
+
+func TestHashChainVerifies(t *testing.T) {
+	store := newChainedStore(t)
+
+	report, err := store.Verify(context.Background())
+	require.NoError(t, err)
+	require.True(t, report.Ok())
+	require.Equal(t, 6, report.Events)
+	require.Equal(t, 6, report.Chained)
+}
+
+func TestHashChainContinuesWithoutTheOption(t *testing.T) {
+	ctx := context.Background()
+	chained := newChainedStore(t)
+	store := NewSqliteStore(chained.db)
+
+	c := newCounter(uuid.New())
+	require.NoError(t, Apply(c.state, counterIncremented{By: 1}))
+	require.NoError(t, Save(ctx, store, c.state))
+
+	report, err := store.Verify(ctx)
+	require.NoError(t, err)
+	require.True(t, report.Ok())
+	require.Equal(t, 7, report.Chained)
+}
+
+func TestHashChainDetectsEdits(t *testing.T) {
+	ctx := context.Background()
+	store := newChainedStore(t)
+
+	_, err := store.db.ExecContext(ctx, `update events set event_data = '{"By":100}' where event_id = 2`)
+	require.NoError(t, err)
+
+	report, err := store.Verify(ctx)
+	require.NoError(t, err)
+	require.NotNil(t, report.BrokenLink)
+	require.Equal(t, int64(2), report.BrokenLink.Position)
+	require.Empty(t, report.Gaps)
+}
+
+func TestHashChainDetectsDeletes(t *testing.T) {
+	ctx := context.Background()
+	store := newChainedStore(t)
+
+	_, err := store.db.ExecContext(ctx, `delete from events where event_id = 5`)
+	require.NoError(t, err)
+
+	report, err := store.Verify(ctx)
+	require.NoError(t, err)
+	require.NotNil(t, report.BrokenLink)
+	require.Equal(t, int64(6), report.BrokenLink.Position)
+	require.Len(t, report.Gaps, 1)
+	require.Equal(t, 2, report.Gaps[0].Sequence)
+}
+
+func TestHashChainVerifiesUpcastEvents(t *testing.T) {
+	ctx := context.Background()
+	store := NewSqliteStore(newTestDatabase(t), WithHashChain())
+	require.NoError(t, store.Initialise(ctx))
+	id := uuid.New()
+
+	registerEvent[titleChanged]("", "chainedTitleChanged", 1)
+	_, err := store.Save(ctx, id, -1, []EventDescriptor{{
+		AggregateID: id,
+		Sequence:    0,
+		Timestamp:   time.Now(),
+		EventType:   "chainedTitleChanged",
+		Version:     1,
+		Event:       titleChanged{Title: "Old"},
+	}})
+	require.NoError(t, err)
+
+	registerEvent[bookRenamed]("", "chainedTitleChanged", 2)
+	RegisterUpcaster("chainedTitleChanged", 1, func(event RawEvent) (RawEvent, error) {
+		old := titleChanged{}
+		if err := json.Unmarshal(event.Data, &old); err != nil {
+			return event, err
+		}
+		data, err := json.Marshal(bookRenamed{NewTitle: old.Title, Reason: "unknown"})
+		return RawEvent{EventType: "chainedTitleChanged", Version: 2, Data: data}, err
+	})
+
+	report, err := store.Verify(ctx)
+	require.NoError(t, err)
+	require.True(t, report.Ok())
+	require.Equal(t, 1, report.Chained)
+}
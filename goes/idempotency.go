@@ -0,0 +1,83 @@
+package goes
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// SaveResult describes the events written by a save.  When the save had an idempotency
+// key which had been used before, nothing is written, and the result is the one from the
+// original save, with Duplicate set.
+type SaveResult struct {
+	AggregateID   uuid.UUID
+	FirstPosition int64
+	LastPosition  int64
+	LastSequence  int
+	Duplicate     bool
+}
+
+type idempotencyKey struct{}
+
+// WithIdempotencyKey returns a context which saves with the given key.  Saving to the
+// same aggregate again with the same key does nothing, so a retried request or a re-run
+// import doesn't add the same events twice.  Keys are per aggregate, so the same key can
+// be used to save to different aggregates.
+func WithIdempotencyKey(ctx context.Context, key string) context.Context {
+	if key == "" {
+		return ctx
+	}
+	return context.WithValue(ctx, idempotencyKey{}, key)
+}
+
+func idempotencyKeyFrom(ctx context.Context) string {
+	key, _ := ctx.Value(idempotencyKey{}).(string)
+	return key
+}
+
+type SaveOption func(ctx context.Context) context.Context
+
+// IdempotencyKey sets the key for a single save, in place of any key on the context.
+func IdempotencyKey(key string) SaveOption {
+	return func(ctx context.Context) context.Context {
+		return WithIdempotencyKey(ctx, key)
+	}
+}
+
+func readIdempotencyKey(ctx context.Context, tx *sql.Tx, aggregateID uuid.UUID, key string) (SaveResult, bool, error) {
+	result := SaveResult{Duplicate: true}
+
+	err := tx.QueryRowContext(ctx, `
+		select aggregate_id, first_event_id, last_event_id, last_sequence
+		from idempotency_keys
+		where aggregate_id = @aggregate_id and idempotency_key = @key`,
+		sql.Named("aggregate_id", aggregateID.String()),
+		sql.Named("key", key),
+	).Scan(&result.AggregateID, &result.FirstPosition, &result.LastPosition, &result.LastSequence)
+
+	if err == sql.ErrNoRows {
+		return SaveResult{}, false, nil
+	}
+	if err != nil {
+		return SaveResult{}, false, err
+	}
+
+	return result, true, nil
+}
+
+func writeIdempotencyKey(ctx context.Context, tx *sql.Tx, key string, result SaveResult) error {
+	_, err := tx.ExecContext(ctx, `
+		insert into
+			idempotency_keys (idempotency_key, aggregate_id, first_event_id, last_event_id, last_sequence, created_at)
+			values (@key, @aggregate_id, @first_event_id, @last_event_id, @last_sequence, @created_at)`,
+		sql.Named("key", key),
+		sql.Named("aggregate_id", result.AggregateID.String()),
+		sql.Named("first_event_id", result.FirstPosition),
+		sql.Named("last_event_id", result.LastPosition),
+		sql.Named("last_sequence", result.LastSequence),
+		sql.Named("created_at", time.Now().UTC()),
+	)
+	return err
+}
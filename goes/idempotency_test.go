@@ -0,0 +1,101 @@
+package goes
+
+import (
+	"context"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/require"
+)
+
+func TestIdempotentSaves(t *testing.T) {
+	stores := map[string]func(t *testing.T) Store{
+		"memory": func(t *testing.T) Store {
+			return NewMemoryStore(nil)
+		},
+		"sqlite": func(t *testing.T) Store {
+			store := NewSqliteStore(newTestDatabase(t))
+			require.NoError(t, store.Initialise(context.Background()))
+			return store
+		},
+	}
+
+	for name, create := range stores {
+		t.Run(name, func(t *testing.T) {
+			ctx := context.Background()
+			store := create(t)
+			id := uuid.New()
+
+			c := newCounter(id)
+			require.NoError(t, Apply(c.state, counterIncremented{By: 2}))
+			require.NoError(t, Save(ctx, store, c.state, IdempotencyKey("request-1")))
+
+			// the same request again, as if the response had been lost and it was retried
+			retried := newCounter(id)
+			require.NoError(t, Apply(retried.state, counterIncremented{By: 2}))
+			require.NoError(t, Save(WithIdempotencyKey(ctx, "request-1"), store, retried.state))
+			require.Equal(t, 0, Sequence(retried.state))
+
+			result, err := store.Save(WithIdempotencyKey(ctx, "request-1"), id, 5, []EventDescriptor{
+				NewEventDescriptor(id, 6, counterIncremented{By: 10}),
+			})
+			require.NoError(t, err)
+			require.True(t, result.Duplicate)
+			require.Equal(t, id, result.AggregateID)
+			require.Equal(t, int64(1), result.FirstPosition)
+			require.Equal(t, 0, result.LastSequence)
+
+			require.NoError(t, Apply(c.state, counterIncremented{By: 3}))
+			require.NoError(t, Save(ctx, store, c.state, IdempotencyKey("request-2")))
+
+			loaded := newCounter(id)
+			require.NoError(t, Load(ctx, store, loaded.state))
+			require.Equal(t, 5, loaded.total)
+			require.Equal(t, 1, Sequence(loaded.state))
+
+			// keys are per aggregate, so another aggregate can use the same key
+			other := newCounter(uuid.New())
+			require.NoError(t, Apply(other.state, counterIncremented{By: 7}))
+			require.NoError(t, Save(ctx, store, other.state, IdempotencyKey("request-1")))
+			require.Equal(t, 0, Sequence(other.state))
+
+			loadedOther := newCounter(other.state.id)
+			require.NoError(t, Load(ctx, store, loadedOther.state))
+			require.Equal(t, 7, loadedOther.total)
+		})
+	}
+}
+
+func TestIdempotencyKeysAreMigratedToBePerAggregate(t *testing.T) {
+	ctx := context.Background()
+	db := newTestDatabase(t)
+	id := uuid.New()
+
+	_, err := db.Exec(`
+		create table idempotency_keys(
+			idempotency_key text primary key,
+			aggregate_id text not null,
+			first_event_id integer not null,
+			last_event_id integer not null,
+			last_sequence integer not null,
+			created_at timestamp not null
+		);
+		insert into idempotency_keys values ('request-1', ?, 1, 1, 0, current_timestamp)`, id.String())
+	require.NoError(t, err)
+
+	store := NewSqliteStore(db)
+	require.NoError(t, store.Initialise(ctx))
+
+	result, err := store.Save(WithIdempotencyKey(ctx, "request-1"), id, -1, []EventDescriptor{
+		NewEventDescriptor(id, 0, counterIncremented{By: 1}),
+	})
+	require.NoError(t, err)
+	require.True(t, result.Duplicate)
+
+	other := uuid.New()
+	result, err = store.Save(WithIdempotencyKey(ctx, "request-1"), other, -1, []EventDescriptor{
+		NewEventDescriptor(other, 0, counterIncremented{By: 1}),
+	})
+	require.NoError(t, err)
+	require.False(t, result.Duplicate)
+}
@@ -0,0 +1,132 @@
+package goes
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"kirjasto/tracing"
+	"strconv"
+	"time"
+
+	"github.com/google/uuid"
+	"go.opentelemetry.io/otel/attribute"
+)
+
+// PointInTime decides whether an event happened at or before a point in an aggregate's history
+type PointInTime func(event EventDescriptor) bool
+
+// AtTime includes events saved at or before the given time
+func AtTime(t time.Time) PointInTime {
+	return func(event EventDescriptor) bool {
+		return !event.Timestamp.After(t)
+	}
+}
+
+// AtSequence includes events with a sequence of at most the one given
+func AtSequence(sequence int) PointInTime {
+	return func(event EventDescriptor) bool {
+		return event.Sequence <= sequence
+	}
+}
+
+// ParsePointInTime accepts a sequence number, an RFC3339 timestamp, or a date, which
+// includes everything saved during that day.
+func ParsePointInTime(value string) (PointInTime, error) {
+	if sequence, err := strconv.Atoi(value); err == nil {
+		return AtSequence(sequence), nil
+	}
+
+	if date, err := time.Parse(time.DateOnly, value); err == nil {
+		return AtTime(date.AddDate(0, 0, 1).Add(-time.Nanosecond)), nil
+	}
+
+	if t, err := time.Parse(time.RFC3339, value); err == nil {
+		return AtTime(t), nil
+	}
+
+	return nil, fmt.Errorf("'%s' is not a sequence, date or timestamp", value)
+}
+
+// LoadAt replays an aggregate's events up to the point given.  Snapshots are not used,
+// as they may have been taken after the point.
+func LoadAt(ctx context.Context, store Store, state *AggregateState, point PointInTime) error {
+	ctx, span := tr.Start(ctx, "load_at")
+	defer span.End()
+
+	events, err := eventsUntil(ctx, store, state.ID(), point)
+	if err != nil {
+		return tracing.Error(span, err)
+	}
+
+	span.SetAttributes(attribute.Int("event.count", len(events)))
+	if len(events) == 0 {
+		return ErrNotFound
+	}
+
+	for _, event := range events {
+		if err := state.ReplayEvent(event); err != nil {
+			return tracing.Error(span, err)
+		}
+	}
+
+	return nil
+}
+
+// eventsUntil reads an aggregate's events up to the point.  An aggregate's events are
+// in sequence and time order, so reading stops at the first event after the point.
+func eventsUntil(ctx context.Context, store Store, aggregateID uuid.UUID, point PointInTime) ([]EventDescriptor, error) {
+	events := []EventDescriptor{}
+	for event, err := range store.Load(ctx, aggregateID, -1) {
+		if err != nil {
+			return nil, err
+		}
+		if !point(event) {
+			break
+		}
+		events = append(events, event)
+	}
+
+	return events, nil
+}
+
+// ViewAt builds an aggregate's view in memory from its events up to the point given,
+// without reading or writing the stored view.  The projection's handlers are given a
+// transaction on db, which is rolled back afterwards, so the projection shouldn't be in
+// use by a store at the same time.
+func (p *SqlProjection[TView]) ViewAt(ctx context.Context, db *sql.DB, store Store, aggregateID uuid.UUID, point PointInTime) (*TView, error) {
+	ctx, span := tr.Start(ctx, "view_at")
+	defer span.End()
+
+	// the events are read before starting the transaction, in case the store uses the same
+	// single connection database
+	events, err := eventsUntil(ctx, store, aggregateID, point)
+	if err != nil {
+		return nil, tracing.Error(span, err)
+	}
+	if len(events) == 0 {
+		return nil, ErrNotFound
+	}
+
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, tracing.Error(span, err)
+	}
+	defer tx.Rollback()
+
+	p.Tx = tx
+	defer func() { p.Tx = nil }()
+
+	view := new(TView)
+	for _, event := range events {
+		handler, found := p.handlers[event.EventType]
+		if !found {
+			return nil, tracing.Errorf(span, "no handler registered for %s", event.EventType)
+		}
+
+		if err := handler(ctx, view, event.Event); err != nil {
+			return nil, tracing.Error(span, err)
+		}
+	}
+
+	return view, nil
+}
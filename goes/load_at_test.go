@@ -0,0 +1,84 @@
+package goes
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/require"
+)
+
+type counterView struct {
+	Total int
+}
+
+func TestLoadAt(t *testing.T) {
+	ctx := context.Background()
+	store := NewMemoryStore(nil)
+	id := uuid.New()
+
+	c := newCounter(id)
+	require.NoError(t, Apply(c.state, counterIncremented{By: 1}))
+	require.NoError(t, Apply(c.state, counterIncremented{By: 2}))
+	require.NoError(t, Apply(c.state, counterIncremented{By: 3}))
+	require.NoError(t, Save(ctx, store, c.state))
+
+	atSequence := newCounter(id)
+	require.NoError(t, LoadAt(ctx, store, atSequence.state, AtSequence(1)))
+	require.Equal(t, 3, atSequence.total)
+	require.Equal(t, 1, Sequence(atSequence.state))
+
+	beforeAll := newCounter(id)
+	err := LoadAt(ctx, store, beforeAll.state, AtTime(time.Now().Add(-time.Hour)))
+	require.ErrorIs(t, err, ErrNotFound)
+}
+
+func TestParsePointInTime(t *testing.T) {
+	event := EventDescriptor{
+		Sequence:  4,
+		Timestamp: time.Date(2024, 1, 1, 23, 0, 0, 0, time.UTC),
+	}
+
+	for value, included := range map[string]bool{
+		"4":                    true,
+		"3":                    false,
+		"2024-01-01":           true,
+		"2023-12-31":           false,
+		"2024-01-01T22:00:00Z": false,
+	} {
+		point, err := ParsePointInTime(value)
+		require.NoError(t, err)
+		require.Equal(t, included, point(event), value)
+	}
+
+	_, err := ParsePointInTime("yesterday")
+	require.Error(t, err)
+}
+
+func TestViewAt(t *testing.T) {
+	ctx := context.Background()
+	db := newTestDatabase(t)
+	store := NewMemoryStore(db)
+	id := uuid.New()
+
+	projection := NewSqlProjection[counterView]()
+	AddProjectionHandler(projection, func(ctx context.Context, view *counterView, event counterIncremented) error {
+		view.Total += event.By
+		return nil
+	})
+	require.NoError(t, store.RegisterProjection("counter_view", projection))
+
+	c := newCounter(id)
+	require.NoError(t, Apply(c.state, counterIncremented{By: 1}))
+	require.NoError(t, Apply(c.state, counterIncremented{By: 2}))
+	require.NoError(t, Save(ctx, store, c.state))
+
+	view, err := projection.ViewAt(ctx, db, store, id, AtSequence(0))
+	require.NoError(t, err)
+	require.Equal(t, 1, view.Total)
+
+	stored, err := projection.View(ctx, db, id)
+	require.NoError(t, err)
+	require.Equal(t, 3, stored.Total)
+}
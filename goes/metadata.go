@@ -0,0 +1,94 @@
+package goes
+
+import (
+	"context"
+	"encoding/json"
+	"kirjasto/tracing"
+	"maps"
+)
+
+const (
+	MetadataTraceID = "trace_id"
+	MetadataCommand = "command"
+	MetadataActor   = "actor"
+	MetadataRequest = "request"
+)
+
+type metadataKey struct{}
+
+// WithMetadata returns a context which will add the key and value to the metadata
+// of every event saved using it.
+func WithMetadata(ctx context.Context, key string, value string) context.Context {
+	metadata := map[string]string{}
+	if existing, ok := ctx.Value(metadataKey{}).(map[string]string); ok {
+		maps.Copy(metadata, existing)
+	}
+
+	metadata[key] = value
+
+	return context.WithValue(ctx, metadataKey{}, metadata)
+}
+
+func WithCommand(ctx context.Context, command string) context.Context {
+	return WithMetadata(ctx, MetadataCommand, command)
+}
+
+func WithActor(ctx context.Context, actor string) context.Context {
+	if actor == "" {
+		return ctx
+	}
+	return WithMetadata(ctx, MetadataActor, actor)
+}
+
+func metadataFromContext(ctx context.Context) map[string]string {
+	metadata := map[string]string{}
+	if existing, ok := ctx.Value(metadataKey{}).(map[string]string); ok {
+		maps.Copy(metadata, existing)
+	}
+
+	if traceID := tracing.TraceID(ctx); traceID != "" {
+		metadata[MetadataTraceID] = traceID
+	}
+
+	return metadata
+}
+
+func addMetadata(ctx context.Context, events []EventDescriptor) {
+	metadata := metadataFromContext(ctx)
+	if len(metadata) == 0 {
+		return
+	}
+
+	for i := range events {
+		if events[i].Metadata == nil {
+			events[i].Metadata = map[string]string{}
+		}
+
+		for key, value := range metadata {
+			if _, found := events[i].Metadata[key]; !found {
+				events[i].Metadata[key] = value
+			}
+		}
+	}
+}
+
+func marshalMetadata(metadata map[string]string) ([]byte, error) {
+	if len(metadata) == 0 {
+		return nil, nil
+	}
+
+	return json.Marshal(metadata)
+}
+
+func unmarshalMetadata(content []byte) (map[string]string, error) {
+	if len(content) == 0 {
+		return nil, nil
+	}
+
+	metadata := map[string]string{}
+	if err := json.Unmarshal(content, &metadata); err != nil {
+		return nil, err
+	}
+
+	return metadata, nil
+}
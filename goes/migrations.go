@@ -0,0 +1,95 @@
+package goes
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+)
+
+type column struct {
+	name       string
+	definition string
+}
+
+// columns added to the events table after it was first created.  New columns must
+// have a default (or allow null) so that existing rows remain valid.
+var eventColumns = []column{
+	{name: "event_version", definition: "integer not null default 1"},
+	{name: "metadata", definition: "text"},
+	{name: "hash", definition: "text"},
+	{name: "key_id", definition: "text"},
+	{name: "aggregate_type", definition: "text not null default ''"},
+}
+
+// migrator is either a database or a transaction
+type migrator interface {
+	Queryable
+	ExecContext(ctx context.Context, query string, args ...any) (sql.Result, error)
+}
+
+func addMissingColumns(ctx context.Context, db migrator, table string, columns []column) error {
+
+	rows, err := db.QueryContext(ctx, fmt.Sprintf("select name from pragma_table_info('%s')", table))
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	existing := map[string]bool{}
+	for rows.Next() {
+		var name string
+		if err := rows.Scan(&name); err != nil {
+			return err
+		}
+		existing[name] = true
+	}
+	if err := rows.Err(); err != nil {
+		return err
+	}
+	rows.Close()
+
+	for _, c := range columns {
+		if existing[c.name] {
+			continue
+		}
+
+		if _, err := db.ExecContext(ctx, fmt.Sprintf("alter table %s add column %s %s", table, c.name, c.definition)); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// scopeIdempotencyKeys recreates the idempotency_keys table from before keys were per
+// aggregate, when the key alone was the primary key
+func scopeIdempotencyKeys(ctx context.Context, db *sql.DB) error {
+	var keyColumns int
+	if err := db.QueryRowContext(ctx, "select count(*) from pragma_table_info('idempotency_keys') where pk > 0").Scan(&keyColumns); err != nil {
+		return err
+	}
+
+	if keyColumns != 1 {
+		return nil
+	}
+
+	_, err := db.ExecContext(ctx, `
+alter table idempotency_keys rename to idempotency_keys_unscoped;
+
+create table idempotency_keys(
+	idempotency_key text not null,
+	aggregate_id text not null,
+	first_event_id integer not null,
+	last_event_id integer not null,
+	last_sequence integer not null,
+	created_at timestamp not null,
+	primary key(aggregate_id, idempotency_key)
+);
+
+insert into idempotency_keys (idempotency_key, aggregate_id, first_event_id, last_event_id, last_sequence, created_at)
+select idempotency_key, aggregate_id, first_event_id, last_event_id, last_sequence, created_at
+from idempotency_keys_unscoped;
+
+drop table idempotency_keys_unscoped;`)
+	return err
+}
@@ -0,0 +1,231 @@
+package goes
+
+import (
+	"context"
+	"database/sql"
+	"kirjasto/tracing"
+	"slices"
+	"time"
+
+	"go.opentelemetry.io/otel/attribute"
+)
+
+// WithOutbox queues events of the given types for the destination, in the same
+// transaction as the events are saved, so that a message is sent for every saved event
+// even if the process stops before sending it.  Messages are sent by whatever reads
+// the outbox, such as the webhook deliverer.
+func WithOutbox(destination string, eventTypes ...string) SqliteOption {
+	return func(s *SqliteStore) {
+		if s.outbox == nil {
+			s.outbox = map[string][]string{}
+		}
+		s.outbox[destination] = append(s.outbox[destination], eventTypes...)
+	}
+}
+
+// OutboxMessage is an event waiting to be sent to a destination.
+type OutboxMessage struct {
+	ID          int64
+	Destination string
+	Attempts    int
+	Event       EventDescriptor
+}
+
+// OutboxAttempt is an entry in the delivery log, recording each attempt to send a message.
+type OutboxAttempt struct {
+	MessageID   int64
+	Destination string
+	Position    int64
+	AttemptedAt time.Time
+	Succeeded   bool
+	Detail      string
+}
+
+func (s *SqliteStore) fillOutbox(ctx context.Context, tx *sql.Tx, events []EventDescriptor) error {
+	if len(s.outbox) == 0 {
+		return nil
+	}
+
+	now := time.Now().UTC()
+	for _, event := range events {
+		for destination, eventTypes := range s.outbox {
+			if !slices.Contains(eventTypes, event.EventType) {
+				continue
+			}
+
+			_, err := tx.ExecContext(ctx, `
+				insert into
+					outbox (destination, event_id, created_at, next_attempt_at)
+					values (@destination, @event_id, @created_at, @created_at)`,
+				sql.Named("destination", destination),
+				sql.Named("event_id", event.Position),
+				sql.Named("created_at", now),
+			)
+			if err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+// DueOutbox returns up to limit messages which are waiting to be sent and whose next
+// attempt is due, oldest first.  Only one process should send the outbox's messages.
+func (s *SqliteStore) DueOutbox(ctx context.Context, limit int) ([]OutboxMessage, error) {
+	ctx, span := tr.Start(ctx, "due_outbox")
+	defer span.End()
+
+	rows, err := s.db.QueryContext(ctx, `
+		select outbox_id, destination, attempts, event_id
+		from outbox
+		where delivered_at is null
+		and abandoned_at is null
+		and next_attempt_at <= @now
+		order by outbox_id asc
+		limit @limit`,
+		sql.Named("now", time.Now().UTC()),
+		sql.Named("limit", limit),
+	)
+	if err != nil {
+		return nil, tracing.Error(span, err)
+	}
+
+	messages := []OutboxMessage{}
+	for rows.Next() {
+		message := OutboxMessage{}
+		if err := rows.Scan(&message.ID, &message.Destination, &message.Attempts, &message.Event.Position); err != nil {
+			rows.Close()
+			return nil, tracing.Error(span, err)
+		}
+		messages = append(messages, message)
+	}
+	rows.Close()
+
+	if err := rows.Err(); err != nil {
+		return nil, tracing.Error(span, err)
+	}
+
+	// the events are read once the rows are closed, as the writer has a single connection
+	for i, message := range messages {
+		event, err := s.Event(ctx, message.Event.Position)
+		if err != nil {
+			return nil, tracing.Error(span, err)
+		}
+		messages[i].Event = event
+	}
+
+	span.SetAttributes(attribute.Int("outbox.due", len(messages)))
+	return messages, nil
+}
+
+// RecordDelivery marks the message as sent, and adds the attempt to the delivery log.
+func (s *SqliteStore) RecordDelivery(ctx context.Context, messageID int64, detail string) error {
+	ctx, span := tr.Start(ctx, "record_delivery")
+	defer span.End()
+
+	err := s.recordAttempt(ctx, messageID, true, detail, `
+		update outbox set
+			attempts = attempts + 1,
+			delivered_at = @now
+		where outbox_id = @outbox_id`,
+	)
+	if err != nil {
+		return tracing.Error(span, err)
+	}
+
+	return nil
+}
+
+// RecordFailedDelivery adds the attempt to the delivery log, and schedules the next
+// attempt for retryAt.  A zero retryAt gives up on the message.
+func (s *SqliteStore) RecordFailedDelivery(ctx context.Context, messageID int64, detail string, retryAt time.Time) error {
+	ctx, span := tr.Start(ctx, "record_failed_delivery")
+	defer span.End()
+
+	update := `
+		update outbox set
+			attempts = attempts + 1,
+			abandoned_at = @now
+		where outbox_id = @outbox_id`
+	if !retryAt.IsZero() {
+		update = `
+		update outbox set
+			attempts = attempts + 1,
+			next_attempt_at = @retry_at
+		where outbox_id = @outbox_id`
+	}
+
+	if err := s.recordAttempt(ctx, messageID, false, detail, update, sql.Named("retry_at", retryAt.UTC())); err != nil {
+		return tracing.Error(span, err)
+	}
+
+	return nil
+}
+
+func (s *SqliteStore) recordAttempt(ctx context.Context, messageID int64, succeeded bool, detail string, update string, args ...any) error {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	now := time.Now().UTC()
+
+	_, err = tx.ExecContext(ctx, update, append(args,
+		sql.Named("outbox_id", messageID),
+		sql.Named("now", now),
+	)...)
+	if err != nil {
+		return err
+	}
+
+	_, err = tx.ExecContext(ctx, `
+		insert into
+			outbox_attempts (outbox_id, attempted_at, succeeded, detail)
+			values (@outbox_id, @attempted_at, @succeeded, @detail)`,
+		sql.Named("outbox_id", messageID),
+		sql.Named("attempted_at", now),
+		sql.Named("succeeded", succeeded),
+		sql.Named("detail", detail),
+	)
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// DeliveryLog returns the most recent attempts to send outbox messages, newest first.
+func (s *SqliteStore) DeliveryLog(ctx context.Context, limit int) ([]OutboxAttempt, error) {
+	ctx, span := tr.Start(ctx, "delivery_log")
+	defer span.End()
+
+	rows, err := s.db.QueryContext(ctx, `
+		select outbox.outbox_id, outbox.destination, outbox.event_id, attempted_at, succeeded, detail
+		from outbox_attempts
+		join outbox on outbox.outbox_id = outbox_attempts.outbox_id
+		order by attempt_id desc
+		limit @limit`,
+		sql.Named("limit", limit),
+	)
+	if err != nil {
+		return nil, tracing.Error(span, err)
+	}
+	defer rows.Close()
+
+	attempts := []OutboxAttempt{}
+	for rows.Next() {
+		a := OutboxAttempt{}
+		if err := rows.Scan(&a.MessageID, &a.Destination, &a.Position, &a.AttemptedAt, &a.Succeeded, &a.Detail); err != nil {
+			return nil, tracing.Error(span, err)
+		}
+		attempts = append(attempts, a)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, tracing.Error(span, err)
+	}
+
+	return attempts, nil
+}
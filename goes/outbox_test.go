@@ -0,0 +1,57 @@
+package goes
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/require"
+)
+
+func TestOutboxIsFilledWithTheSave(t *testing.T) {
+	ctx := context.Background()
+	store := NewSqliteStore(newTestDatabase(t), WithOutbox("http://example.com/hook", "counterIncremented"))
+	require.NoError(t, store.Initialise(ctx))
+
+	c := newCounter(uuid.New())
+	require.NoError(t, Apply(c.state, counterIncremented{By: 1}))
+	require.NoError(t, Apply(c.state, counterIncremented{By: 2}))
+	require.NoError(t, Save(ctx, store, c.state))
+
+	messages, err := store.DueOutbox(ctx, 10)
+	require.NoError(t, err)
+	require.Len(t, messages, 2)
+	require.Equal(t, "http://example.com/hook", messages[0].Destination)
+	require.Equal(t, &counterIncremented{By: 1}, messages[0].Event.Event)
+
+	require.NoError(t, store.RecordDelivery(ctx, messages[0].ID, "200 OK"))
+	require.NoError(t, store.RecordFailedDelivery(ctx, messages[1].ID, "500 Internal Server Error", time.Now().Add(time.Hour)))
+
+	messages, err = store.DueOutbox(ctx, 10)
+	require.NoError(t, err)
+	require.Empty(t, messages)
+
+	log, err := store.DeliveryLog(ctx, 10)
+	require.NoError(t, err)
+	require.Len(t, log, 2)
+	require.False(t, log[0].Succeeded)
+	require.True(t, log[1].Succeeded)
+}
+
+func TestOutboxIsRolledBackWithTheSave(t *testing.T) {
+	ctx := context.Background()
+	store := NewSqliteStore(newTestDatabase(t), WithOutbox("http://example.com/hook", "counterIncremented"))
+	require.NoError(t, store.RegisterProjection("failing", StatelessProjection(func(ctx context.Context, event EventDescriptor) error {
+		return context.Canceled
+	})))
+	require.NoError(t, store.Initialise(ctx))
+
+	c := newCounter(uuid.New())
+	require.NoError(t, Apply(c.state, counterIncremented{By: 1}))
+	require.Error(t, Save(ctx, store, c.state))
+
+	messages, err := store.DueOutbox(ctx, 10)
+	require.NoError(t, err)
+	require.Empty(t, messages)
+}
@@ -0,0 +1,252 @@
+package goes
+
+import (
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"database/sql"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+	"sync"
+
+	"github.com/google/uuid"
+)
+
+// Redacted replaces the value of personal fields once their subject's key has been
+// deleted.
+const Redacted = "[redacted]"
+
+const encryptedPrefix = "encrypted:"
+
+// DataSubject is implemented by events whose personal fields belong to someone other
+// than the aggregate, such as the person a book was lent to.  Events which don't
+// implement it use the aggregate's ID as the subject.
+//
+// Fields are marked as personal with a `goes:"personal"` tag, and must be strings.  They
+// are encrypted with a key per subject when the event is saved, and once the subject's
+// key is deleted (see ForgetSubject) the fields read as Redacted.  Snapshots are stored
+// unencrypted, so must not contain personal data.
+type DataSubject interface {
+	DataSubject() string
+}
+
+func subjectOf(e EventDescriptor) string {
+	if subject, ok := e.Event.(DataSubject); ok {
+		return subject.DataSubject()
+	}
+	return e.AggregateID.String()
+}
+
+var personalTypes sync.Map
+
+// hasPersonalFields reports whether the event, or a struct within it, has any fields
+// tagged as personal
+func hasPersonalFields(event any) bool {
+	t := reflect.TypeOf(event)
+	if t == nil {
+		return false
+	}
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+
+	if cached, found := personalTypes.Load(t); found {
+		return cached.(bool)
+	}
+
+	found := false
+	if t.Kind() == reflect.Struct {
+		for i := range t.NumField() {
+			field := t.Field(i)
+			if isPersonal(field) || (field.Type.Kind() == reflect.Struct && hasPersonalFields(reflect.Zero(field.Type).Interface())) {
+				found = true
+				break
+			}
+		}
+	}
+
+	personalTypes.Store(t, found)
+	return found
+}
+
+func isPersonal(field reflect.StructField) bool {
+	return field.Tag.Get("goes") == "personal"
+}
+
+// encryptEvent returns a copy of the event with its personal fields encrypted
+func encryptEvent(event any, key []byte) (any, error) {
+	return replacePersonalFields(event, func(value string) (string, error) {
+		return encrypt(key, value)
+	})
+}
+
+// redactEvent returns a copy of the event with its personal fields replaced by Redacted
+func redactEvent(event any) (any, error) {
+	return replacePersonalFields(event, func(value string) (string, error) {
+		return Redacted, nil
+	})
+}
+
+func replacePersonalFields(event any, replace func(value string) (string, error)) (any, error) {
+	value := reflect.ValueOf(event)
+	for value.Kind() == reflect.Pointer {
+		value = value.Elem()
+	}
+
+	copied := reflect.New(value.Type()).Elem()
+	copied.Set(value)
+
+	err := walkPersonalFields(copied, func(field reflect.Value) error {
+		replaced, err := replace(field.String())
+		if err != nil {
+			return err
+		}
+		field.SetString(replaced)
+		return nil
+	})
+
+	return copied.Interface(), err
+}
+
+// decryptEvent decrypts the event's personal fields in place, replacing them with
+// Redacted when there is no key.
+func decryptEvent(event any, key []byte) error {
+	value := reflect.ValueOf(event)
+	for value.Kind() == reflect.Pointer {
+		value = value.Elem()
+	}
+
+	return walkPersonalFields(value, func(field reflect.Value) error {
+		if !strings.HasPrefix(field.String(), encryptedPrefix) {
+			return nil
+		}
+
+		if key == nil {
+			field.SetString(Redacted)
+			return nil
+		}
+
+		decrypted, err := decrypt(key, field.String())
+		if err != nil {
+			return err
+		}
+		field.SetString(decrypted)
+		return nil
+	})
+}
+
+func walkPersonalFields(value reflect.Value, action func(field reflect.Value) error) error {
+	if value.Kind() != reflect.Struct {
+		return nil
+	}
+
+	for i := range value.NumField() {
+		field := value.Type().Field(i)
+
+		if isPersonal(field) {
+			if field.Type.Kind() != reflect.String {
+				return fmt.Errorf("personal field %s.%s must be a string", value.Type().Name(), field.Name)
+			}
+			if err := action(value.Field(i)); err != nil {
+				return err
+			}
+			continue
+		}
+
+		if field.Type.Kind() == reflect.Struct && field.IsExported() {
+			if err := walkPersonalFields(value.Field(i), action); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+func encrypt(key []byte, plaintext string) (string, error) {
+	gcm, err := newGCM(key)
+	if err != nil {
+		return "", err
+	}
+
+	nonce := make([]byte, gcm.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return "", err
+	}
+
+	sealed := gcm.Seal(nonce, nonce, []byte(plaintext), nil)
+	return encryptedPrefix + base64.StdEncoding.EncodeToString(sealed), nil
+}
+
+func decrypt(key []byte, value string) (string, error) {
+	sealed, err := base64.StdEncoding.DecodeString(strings.TrimPrefix(value, encryptedPrefix))
+	if err != nil {
+		return "", err
+	}
+
+	gcm, err := newGCM(key)
+	if err != nil {
+		return "", err
+	}
+
+	if len(sealed) < gcm.NonceSize() {
+		return "", errors.New("encrypted value is too short")
+	}
+
+	nonce, ciphertext := sealed[:gcm.NonceSize()], sealed[gcm.NonceSize():]
+	plaintext, err := gcm.Open(nil, nonce, ciphertext, nil)
+	if err != nil {
+		return "", err
+	}
+
+	return string(plaintext), nil
+}
+
+func newGCM(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+func newEncryptionKey() ([]byte, error) {
+	key := make([]byte, 32)
+	_, err := rand.Read(key)
+	return key, err
+}
+
+// subjectKey reads the subject's key, creating one if it doesn't exist yet
+func subjectKey(ctx context.Context, tx *sql.Tx, subject string) (string, []byte, error) {
+	var keyID string
+	var key []byte
+
+	err := tx.QueryRowContext(ctx,
+		"select key_id, key_data from encryption_keys where subject = @subject",
+		sql.Named("subject", subject),
+	).Scan(&keyID, &key)
+	if err == nil {
+		return keyID, key, nil
+	}
+	if err != sql.ErrNoRows {
+		return "", nil, err
+	}
+
+	keyID = uuid.NewString()
+	if key, err = newEncryptionKey(); err != nil {
+		return "", nil, err
+	}
+
+	_, err = tx.ExecContext(ctx,
+		"insert into encryption_keys (key_id, subject, key_data) values (@key_id, @subject, @key_data)",
+		sql.Named("key_id", keyID),
+		sql.Named("subject", subject),
+		sql.Named("key_data", key),
+	)
+
+	return keyID, key, err
+}
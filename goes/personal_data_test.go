@@ -0,0 +1,81 @@
+package goes
+
+import (
+	"context"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/require"
+)
+
+type bookLent struct {
+	Isbn     string
+	Borrower string `goes:"personal"`
+	Note     string `goes:"personal"`
+}
+
+func (e bookLent) DataSubject() string {
+	return "borrower:" + e.Borrower
+}
+
+type lending struct {
+	state *AggregateState
+	lent  []bookLent
+}
+
+func newLending(id uuid.UUID) *lending {
+	l := &lending{state: NewAggregateState("lending")}
+	SetID(l.state, id)
+
+	Register(l.state, func(e bookLent) {
+		l.lent = append(l.lent, e)
+	})
+
+	return l
+}
+
+func testPersonalData(t *testing.T, store Store) {
+	ctx := context.Background()
+	id := uuid.New()
+
+	l := newLending(id)
+	require.NoError(t, Apply(l.state, bookLent{Isbn: "123", Borrower: "alice", Note: "careful"}))
+	require.NoError(t, Apply(l.state, bookLent{Isbn: "456", Borrower: "bob", Note: "no rush"}))
+	require.NoError(t, Save(ctx, store, l.state))
+
+	loaded := newLending(id)
+	require.NoError(t, Load(ctx, store, loaded.state))
+	require.Equal(t, "alice", loaded.lent[0].Borrower)
+	require.Equal(t, "careful", loaded.lent[0].Note)
+
+	require.NoError(t, store.ForgetSubject(ctx, "borrower:alice"))
+
+	forgotten := newLending(id)
+	require.NoError(t, Load(ctx, store, forgotten.state))
+	require.Equal(t, bookLent{Isbn: "123", Borrower: Redacted, Note: Redacted}, forgotten.lent[0])
+	require.Equal(t, bookLent{Isbn: "456", Borrower: "bob", Note: "no rush"}, forgotten.lent[1])
+}
+
+func TestMemoryStorePersonalData(t *testing.T) {
+	testPersonalData(t, NewMemoryStore(nil))
+}
+
+func TestSqliteStorePersonalData(t *testing.T) {
+	ctx := context.Background()
+	store := NewSqliteStore(newTestDatabase(t))
+	require.NoError(t, store.Initialise(ctx))
+
+	testPersonalData(t, store)
+
+	rows, err := store.db.QueryContext(ctx, "select event_data from events")
+	require.NoError(t, err)
+	defer rows.Close()
+
+	for rows.Next() {
+		var data string
+		require.NoError(t, rows.Scan(&data))
+		require.Contains(t, data, `"Isbn":`)
+		require.NotContains(t, data, "bob")
+		require.NotContains(t, data, "careful")
+	}
+}
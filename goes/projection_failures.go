@@ -0,0 +1,226 @@
+package goes
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"kirjasto/tracing"
+	"time"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const errProjectionFaulted = "skipped, as the projection is faulted"
+
+// ProjectionFailure is an event which an isolated projection has not processed
+type ProjectionFailure struct {
+	ID         int64
+	Projection string
+	Position   int64
+	EventType  string
+	Error      string
+	FailedAt   time.Time
+}
+
+// projectIsolated runs the isolated projections over the events which have just been
+// written.  Each projection runs in a savepoint, so a failure only undoes its own changes,
+// and every event it was given is recorded as failed.  Once a projection has failures it
+// is faulted, and skips all events until the failures are retried.
+func (s *SqliteStore) projectIsolated(ctx context.Context, tx *sql.Tx, events []EventDescriptor) error {
+	for name, projection := range s.projections.isolated() {
+		faulted, err := isFaulted(ctx, tx, name)
+		if err != nil {
+			return err
+		}
+
+		if faulted {
+			if err := recordFailures(ctx, tx, name, events, -1, nil); err != nil {
+				return err
+			}
+			continue
+		}
+
+		if _, err := tx.ExecContext(ctx, "savepoint isolated_projection"); err != nil {
+			return err
+		}
+
+		failedAt, projectionErr := runProjection(ctx, tx, projection, events)
+		if projectionErr != nil {
+			if _, err := tx.ExecContext(ctx, "rollback to isolated_projection"); err != nil {
+				return err
+			}
+
+			// the save still succeeds, so the error is recorded without failing the span
+			trace.SpanFromContext(ctx).RecordError(projectionErr, trace.WithAttributes(
+				attribute.String("projection.name", name),
+			))
+			if err := recordFailures(ctx, tx, name, events, failedAt, projectionErr); err != nil {
+				return err
+			}
+		}
+
+		if _, err := tx.ExecContext(ctx, "release isolated_projection"); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// runProjection loads, projects and saves the events, returning the index of the event
+// which failed, or -1 if the projection failed while loading or saving.
+func runProjection(ctx context.Context, tx *sql.Tx, projection Projection, events []EventDescriptor) (int, error) {
+	if err := projection.Load(ctx, tx); err != nil {
+		return -1, err
+	}
+
+	for i, event := range events {
+		if err := projection.Project(ctx, event); err != nil {
+			return i, err
+		}
+	}
+
+	if err := projection.Save(ctx, tx); err != nil {
+		return -1, err
+	}
+
+	return -1, nil
+}
+
+// recordFailures adds every event to the projection's failures, as none of them have
+// been projected.  The event at failedAt is given the error, and the others are marked
+// as skipped.
+func recordFailures(ctx context.Context, tx *sql.Tx, name string, events []EventDescriptor, failedAt int, failure error) error {
+	for i, event := range events {
+		message := errProjectionFaulted
+		if failure != nil && (i == failedAt || failedAt == -1) {
+			message = failure.Error()
+		}
+
+		_, err := tx.ExecContext(ctx, `
+			insert into
+				projection_failures (name, event_id, event_type, error, failed_at)
+				values (@name, @event_id, @event_type, @error, @failed_at)`,
+			sql.Named("name", name),
+			sql.Named("event_id", event.Position),
+			sql.Named("event_type", event.EventType),
+			sql.Named("error", message),
+			sql.Named("failed_at", time.Now().UTC()),
+		)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func isFaulted(ctx context.Context, reader Readable, name string) (bool, error) {
+	var count int
+	err := reader.QueryRowContext(ctx,
+		"select count(*) from projection_failures where name = @name",
+		sql.Named("name", name),
+	).Scan(&count)
+
+	return count > 0, err
+}
+
+func clearFailures(ctx context.Context, tx *sql.Tx, name string) error {
+	_, err := tx.ExecContext(ctx, "delete from projection_failures where name = @name", sql.Named("name", name))
+	return err
+}
+
+// Faulted reports whether the named projection has failed to process an event, in which
+// case its view is missing every event since, until the failures are retried.
+func (s *SqliteStore) Faulted(ctx context.Context, name string) (bool, error) {
+	ctx, span := tr.Start(ctx, "faulted")
+	defer span.End()
+
+	faulted, err := isFaulted(ctx, s.db, name)
+	if err != nil {
+		return false, tracing.Error(span, err)
+	}
+
+	return faulted, nil
+}
+
+// Failures lists the events which isolated projections have failed to process, oldest first
+func (s *SqliteStore) Failures(ctx context.Context) ([]ProjectionFailure, error) {
+	ctx, span := tr.Start(ctx, "failures")
+	defer span.End()
+
+	rows, err := s.db.QueryContext(ctx, `
+		select failure_id, name, event_id, event_type, error, failed_at
+		from projection_failures
+		order by failure_id asc`)
+	if err != nil {
+		return nil, tracing.Error(span, err)
+	}
+	defer rows.Close()
+
+	failures := []ProjectionFailure{}
+	for rows.Next() {
+		f := ProjectionFailure{}
+		if err := rows.Scan(&f.ID, &f.Projection, &f.Position, &f.EventType, &f.Error, &f.FailedAt); err != nil {
+			return nil, tracing.Error(span, err)
+		}
+		failures = append(failures, f)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, tracing.Error(span, err)
+	}
+
+	return failures, nil
+}
+
+// RetryFailures projects the named projection's failed events again, in the order they
+// were saved.  If they all succeed the failures are removed and the projection is no
+// longer faulted, otherwise nothing is changed and the error is returned.
+func (s *SqliteStore) RetryFailures(ctx context.Context, name string) (int, error) {
+	ctx, span := tr.Start(ctx, "retry_failures")
+	defer span.End()
+
+	span.SetAttributes(attribute.String("projection.name", name))
+
+	registered, found := s.projections.projections[name]
+	if !found {
+		return 0, tracing.Errorf(span, "no projection called '%s' is registered", name)
+	}
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, tracing.Error(span, err)
+	}
+	defer tx.Rollback()
+
+	events := []EventDescriptor{}
+	query := eventSelect + `
+		where event_id in (select event_id from projection_failures where name = @name)
+		order by event_id asc`
+	for event, err := range queryEvents(ctx, tx, query, sql.Named("name", name)) {
+		if err != nil {
+			return 0, tracing.Error(span, err)
+		}
+		events = append(events, event)
+	}
+
+	if failedAt, err := runProjection(ctx, tx, registered.projection, events); err != nil {
+		if failedAt >= 0 {
+			return 0, tracing.Error(span, fmt.Errorf("event %d: %w", events[failedAt].Position, err))
+		}
+		return 0, tracing.Error(span, err)
+	}
+
+	if err := clearFailures(ctx, tx, name); err != nil {
+		return 0, tracing.Error(span, err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return 0, tracing.Error(span, err)
+	}
+
+	span.SetAttributes(attribute.Int("event.count", len(events)))
+	return len(events), nil
+}
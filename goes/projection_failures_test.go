@@ -0,0 +1,72 @@
+package goes
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/require"
+)
+
+func TestIsolatedProjectionFailures(t *testing.T) {
+	ctx := context.Background()
+	db := newTestDatabase(t)
+	store := NewSqliteStore(db)
+
+	broken := true
+	table, err := NewTableProjection[counterRow]("counters")
+	require.NoError(t, err)
+	AddTableHandler(table, func(ctx context.Context, table *TableProjection[counterRow], event counterIncremented) error {
+		// the write is undone by the savepoint when the handler fails
+		if err := table.Upsert(ctx, counterRow{ID: "total", Total: event.By}); err != nil {
+			return err
+		}
+		if broken {
+			return errors.New("projection is broken")
+		}
+		return nil
+	})
+
+	require.NoError(t, store.RegisterProjection("counters", table, Isolate()))
+	require.NoError(t, store.Initialise(ctx))
+
+	c := newCounter(uuid.New())
+	require.NoError(t, Apply(c.state, counterIncremented{By: 1}))
+	require.NoError(t, Save(ctx, store, c.state))
+
+	// the projection is faulted, so later events are skipped rather than projected
+	broken = false
+	require.NoError(t, Apply(c.state, counterIncremented{By: 2}))
+	require.NoError(t, Save(ctx, store, c.state))
+
+	faulted, err := store.Faulted(ctx, "counters")
+	require.NoError(t, err)
+	require.True(t, faulted)
+
+	failures, err := store.Failures(ctx)
+	require.NoError(t, err)
+	require.Len(t, failures, 2)
+	require.Equal(t, "projection is broken", failures[0].Error)
+	require.Equal(t, errProjectionFaulted, failures[1].Error)
+
+	count, err := store.RetryFailures(ctx, "counters")
+	require.NoError(t, err)
+	require.Equal(t, 2, count)
+
+	rows, err := table.Query(ctx, db, "")
+	require.NoError(t, err)
+	require.Equal(t, 2, rows[0].Total)
+
+	failures, err = store.Failures(ctx)
+	require.NoError(t, err)
+	require.Empty(t, failures)
+
+	faulted, err = store.Faulted(ctx, "counters")
+	require.NoError(t, err)
+	require.False(t, faulted)
+
+	loaded := newCounter(c.state.ID())
+	require.NoError(t, Load(ctx, store, loaded.state))
+	require.Equal(t, 3, loaded.total)
+}
@@ -0,0 +1,128 @@
+package goes
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"kirjasto/tracing"
+
+	"go.opentelemetry.io/otel/attribute"
+)
+
+// VersionedProjection can be implemented by projections to have them rebuilt when their
+// version changes, which should happen whenever the shape of the view or the behaviour
+// of a handler changes.
+type VersionedProjection interface {
+	Version() int
+}
+
+type StalePolicy int
+
+const (
+	// RebuildStale wipes and rebuilds projections whose version has changed
+	RebuildStale StalePolicy = iota
+	// RefuseStale makes Initialise fail when a projection's version has changed
+	RefuseStale
+	// IgnoreStale leaves projections as they are, for commands which rebuild them anyway
+	IgnoreStale
+)
+
+func ParseStalePolicy(value string) (StalePolicy, error) {
+	switch value {
+	case "rebuild":
+		return RebuildStale, nil
+	case "refuse":
+		return RefuseStale, nil
+	case "ignore":
+		return IgnoreStale, nil
+	}
+
+	return 0, fmt.Errorf("unknown stale projection policy '%s', expected one of: rebuild, refuse, ignore", value)
+}
+
+type ErrStaleProjection struct {
+	Name           string
+	StoredVersion  int
+	CurrentVersion int
+}
+
+func (e *ErrStaleProjection) Error() string {
+	if e.StoredVersion == 0 {
+		return fmt.Sprintf("projection %s has never been built, run 'goes rebuild views %s'", e.Name, e.Name)
+	}
+	return fmt.Sprintf("projection %s is version %d but the current version is %d, run 'goes rebuild views %s'", e.Name, e.StoredVersion, e.CurrentVersion, e.Name)
+}
+
+func (s *SqliteStore) checkProjectionVersions(ctx context.Context) error {
+	ctx, span := tr.Start(ctx, "check_projection_versions")
+	defer span.End()
+
+	if s.stalePolicy == IgnoreStale {
+		return nil
+	}
+
+	for _, registered := range s.Projections() {
+		versioned, ok := registered.Projection.(VersionedProjection)
+		if !ok {
+			continue
+		}
+
+		stored, err := readProjectionVersion(ctx, s.db, registered.Name)
+		if err != nil {
+			return tracing.Error(span, err)
+		}
+
+		if stored == versioned.Version() {
+			continue
+		}
+
+		span.AddEvent("stale_projection")
+		span.SetAttributes(
+			attribute.String("projection.name", registered.Name),
+			attribute.Int("projection.stored_version", stored),
+			attribute.Int("projection.version", versioned.Version()),
+		)
+
+		if s.stalePolicy == RefuseStale {
+			return tracing.Error(span, &ErrStaleProjection{
+				Name:           registered.Name,
+				StoredVersion:  stored,
+				CurrentVersion: versioned.Version(),
+			})
+		}
+
+		if err := s.Rebuild(ctx, registered.Projection); err != nil {
+			return tracing.Error(span, err)
+		}
+	}
+
+	return nil
+}
+
+// readProjectionVersion returns 0 if the projection has no version recorded
+func readProjectionVersion(ctx context.Context, reader Readable, name string) (int, error) {
+	var version int
+	err := reader.QueryRowContext(ctx,
+		`select version from projection_versions where name = @name`,
+		sql.Named("name", name),
+	).Scan(&version)
+
+	if err == sql.ErrNoRows {
+		return 0, nil
+	}
+
+	return version, err
+}
+
+func writeProjectionVersion(ctx context.Context, tx *sql.Tx, name string, version int) error {
+	_, err := tx.ExecContext(ctx, `
+		insert into
+			projection_versions (name, version)
+			values (@name, @version)
+		on conflict(name) do update set
+			version = @version`,
+		sql.Named("name", name),
+		sql.Named("version", version),
+	)
+	return err
+}
@@ -0,0 +1,74 @@
+package goes
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/require"
+)
+
+type versionedProjection struct {
+	Projection
+	version   int
+	projected int
+}
+
+func (p *versionedProjection) Version() int {
+	return p.version
+}
+
+func newVersionedProjection(version int) *versionedProjection {
+	p := &versionedProjection{version: version}
+	p.Projection = StatelessProjection(func(ctx context.Context, event EventDescriptor) error {
+		p.projected++
+		return nil
+	})
+	return p
+}
+
+func openVersionedStore(t *testing.T, db *sql.DB, projection Projection, policy StalePolicy) error {
+	store := NewSqliteStore(db, WithStalePolicy(policy))
+	require.NoError(t, store.RegisterProjection("versioned", projection))
+	return store.Initialise(context.Background())
+}
+
+func TestChangedProjectionVersionsAreRebuilt(t *testing.T) {
+	ctx := context.Background()
+	db := newTestDatabase(t)
+
+	store := NewSqliteStore(db)
+	require.NoError(t, store.Initialise(ctx))
+	c := newCounter(uuid.New())
+	require.NoError(t, Apply(c.state, counterIncremented{By: 1}))
+	require.NoError(t, Apply(c.state, counterIncremented{By: 1}))
+	require.NoError(t, Save(ctx, store, c.state))
+
+	first := newVersionedProjection(1)
+	require.NoError(t, openVersionedStore(t, db, first, RebuildStale))
+	require.Equal(t, 2, first.projected)
+
+	unchanged := newVersionedProjection(1)
+	require.NoError(t, openVersionedStore(t, db, unchanged, RebuildStale))
+	require.Equal(t, 0, unchanged.projected)
+
+	changed := newVersionedProjection(2)
+	require.NoError(t, openVersionedStore(t, db, changed, RebuildStale))
+	require.Equal(t, 2, changed.projected)
+}
+
+func TestChangedProjectionVersionsCanBeRefused(t *testing.T) {
+	db := newTestDatabase(t)
+
+	require.NoError(t, openVersionedStore(t, db, newVersionedProjection(1), RebuildStale))
+
+	refused := newVersionedProjection(2)
+	err := openVersionedStore(t, db, refused, RefuseStale)
+
+	var stale *ErrStaleProjection
+	require.True(t, errors.As(err, &stale))
+	require.Equal(t, ErrStaleProjection{Name: "versioned", StoredVersion: 1, CurrentVersion: 2}, *stale)
+	require.Equal(t, 0, refused.projected)
+}
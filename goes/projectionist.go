@@ -4,26 +4,160 @@ import (
 	"context"
 	"database/sql"
 	"fmt"
+	"iter"
 	"kirjasto/tracing"
+	"slices"
+	"strings"
 )
 
+type projectionSettings struct {
+	async   bool
+	isolate bool
+}
+
+type ProjectionOption func(o *projectionSettings)
+
+// Async projections are not run when events are saved, but are caught up in batches
+// by a background projector, which tracks how far each projection has got in the
+// projection_checkpoints table.
+func Async() ProjectionOption {
+	return func(o *projectionSettings) {
+		o.async = true
+	}
+}
+
+// Isolate stops the projection's failures from rolling back the events being saved.
+// Instead, the failing events are recorded in the projection_failures table, and the
+// projection is faulted until they are retried successfully.  Projections are strict by
+// default, where any failure stops the events from being saved.
+func Isolate() ProjectionOption {
+	return func(o *projectionSettings) {
+		o.isolate = true
+	}
+}
+
+type registeredProjection struct {
+	projection Projection
+	settings   projectionSettings
+}
+
 type Projectionist struct {
-	projections map[string]Projection
+	projections map[string]*registeredProjection
+
+	// when set, async projections are treated as inline ones
+	asyncInline bool
+
+	// when set, isolated projections are treated as strict ones
+	strictOnly bool
 }
 
-func (p *Projectionist) RegisterProjection(name string, projection Projection) error {
+func (p *Projectionist) RegisterProjection(name string, projection Projection, options ...ProjectionOption) error {
 	if _, found := p.projections[name]; found {
 		return fmt.Errorf("a projection with the name '%s' already exists", name)
 	}
 
-	p.projections[name] = projection
+	settings := projectionSettings{}
+	for _, option := range options {
+		option(&settings)
+	}
+
+	p.projections[name] = &registeredProjection{
+		projection: projection,
+		settings:   settings,
+	}
 	return nil
 }
+
+type RegisteredProjection struct {
+	Name       string
+	Projection Projection
+	Async      bool
+	Isolated   bool
+}
+
+// Projections lists the registered projections, ordered by name
+func (p *Projectionist) Projections() []RegisteredProjection {
+	all := make([]RegisteredProjection, 0, len(p.projections))
+	for name, registered := range p.projections {
+		all = append(all, RegisteredProjection{
+			Name:       name,
+			Projection: registered.projection,
+			Async:      p.isAsync(registered),
+			Isolated:   p.isIsolated(registered),
+		})
+	}
+
+	slices.SortFunc(all, func(a, b RegisteredProjection) int {
+		return strings.Compare(a.Name, b.Name)
+	})
+
+	return all
+}
+
+func (p *Projectionist) isAsync(registered *registeredProjection) bool {
+	return registered.settings.async && !p.asyncInline
+}
+
+func (p *Projectionist) isIsolated(registered *registeredProjection) bool {
+	return registered.settings.isolate && !p.strictOnly && !p.isAsync(registered)
+}
+
+// inline returns the strict projections which are run as part of saving events
+func (p *Projectionist) inline() iter.Seq2[string, Projection] {
+	return func(yield func(string, Projection) bool) {
+		for name, registered := range p.projections {
+			if p.isAsync(registered) || p.isIsolated(registered) {
+				continue
+			}
+			if !yield(name, registered.projection) {
+				return
+			}
+		}
+	}
+}
+
+// isolated returns the projections which are run after the strict ones when saving
+// events, and whose failures are recorded rather than returned
+func (p *Projectionist) isolated() iter.Seq2[string, Projection] {
+	return func(yield func(string, Projection) bool) {
+		for name, registered := range p.projections {
+			if !p.isIsolated(registered) {
+				continue
+			}
+			if !yield(name, registered.projection) {
+				return
+			}
+		}
+	}
+}
+
+func (p *Projectionist) async() iter.Seq2[string, Projection] {
+	return func(yield func(string, Projection) bool) {
+		for name, registered := range p.projections {
+			if !p.isAsync(registered) {
+				continue
+			}
+			if !yield(name, registered.projection) {
+				return
+			}
+		}
+	}
+}
+
+func (p *Projectionist) nameOf(projection Projection) (string, bool) {
+	for name, registered := range p.projections {
+		if registered.projection == projection {
+			return name, true
+		}
+	}
+	return "", false
+}
+
 func (p *Projectionist) Load(ctx context.Context, tx *sql.Tx) error {
 	ctx, span := tr.Start(ctx, "load")
 	defer span.End()
 
-	for _, projection := range p.projections {
+	for _, projection := range p.inline() {
 		if err := projection.Load(ctx, tx); err != nil {
 			return tracing.Error(span, err)
 		}
@@ -36,7 +170,7 @@ func (p *Projectionist) Project(ctx context.Context, event EventDescriptor) erro
 	ctx, span := tr.Start(ctx, "project")
 	defer span.End()
 
-	for _, projection := range p.projections {
+	for _, projection := range p.inline() {
 		if err := projection.Project(ctx, event); err != nil {
 			return tracing.Error(span, err)
 		}
@@ -49,7 +183,7 @@ func (p *Projectionist) Save(ctx context.Context, tx *sql.Tx) error {
 	ctx, span := tr.Start(ctx, "save")
 	defer span.End()
 
-	for _, projection := range p.projections {
+	for _, projection := range p.inline() {
 		if err := projection.Save(ctx, tx); err != nil {
 			return tracing.Error(span, err)
 		}
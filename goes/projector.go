@@ -0,0 +1,150 @@
+package goes
+
+import (
+	"context"
+	"database/sql"
+	"kirjasto/tracing"
+	"time"
+
+	"go.opentelemetry.io/otel/attribute"
+)
+
+const DefaultBatchSize = 500
+
+// CatchUp runs all async projections until they have processed every event in the
+// store, committing each batch of events along with the projection's checkpoint.
+// It returns the total number of events projected.
+func (s *SqliteStore) CatchUp(ctx context.Context, batchSize int) (int, error) {
+	ctx, span := tr.Start(ctx, "catch_up")
+	defer span.End()
+
+	total := 0
+	for name, projection := range s.projections.async() {
+		for {
+			count, err := s.projectBatch(ctx, name, projection, batchSize)
+			if err != nil {
+				return total, tracing.Error(span, err)
+			}
+
+			total += count
+			if count < batchSize {
+				break
+			}
+		}
+	}
+
+	span.SetAttributes(attribute.Int("event.count", total))
+	return total, nil
+}
+
+// RunProjector catches up the async projections every interval, until the context is cancelled.
+func (s *SqliteStore) RunProjector(ctx context.Context, batchSize int, interval time.Duration) error {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		if _, err := s.CatchUp(ctx, batchSize); err != nil {
+			return err
+		}
+
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+		}
+	}
+}
+
+func (s *SqliteStore) projectBatch(ctx context.Context, name string, projection Projection, batchSize int) (int, error) {
+	ctx, span := tr.Start(ctx, "project_batch")
+	defer span.End()
+
+	span.SetAttributes(attribute.String("projection.name", name))
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, tracing.Error(span, err)
+	}
+	defer tx.Rollback()
+
+	checkpoint, found, err := readCheckpoint(ctx, tx, name)
+	if err != nil {
+		return 0, tracing.Error(span, err)
+	}
+
+	if err := projection.Load(ctx, tx); err != nil {
+		return 0, tracing.Error(span, err)
+	}
+
+	// without a checkpoint the projection might have been populated inline
+	// before, so start again from an empty view
+	if !found {
+		if err := projection.Wipe(ctx); err != nil {
+			return 0, tracing.Error(span, err)
+		}
+	}
+
+	count := 0
+	position := checkpoint
+	for event, err := range eventsAfter(ctx, tx, checkpoint, batchSize) {
+		if err != nil {
+			return 0, tracing.Error(span, err)
+		}
+
+		if err := projection.Project(ctx, event); err != nil {
+			return 0, tracing.Error(span, err)
+		}
+
+		count++
+		position = event.Position
+	}
+
+	if err := projection.Save(ctx, tx); err != nil {
+		return 0, tracing.Error(span, err)
+	}
+
+	if err := writeCheckpoint(ctx, tx, name, position); err != nil {
+		return 0, tracing.Error(span, err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return 0, tracing.Error(span, err)
+	}
+
+	span.SetAttributes(
+		attribute.Int("event.count", count),
+		attribute.Int64("projection.checkpoint", position),
+	)
+
+	return count, nil
+}
+
+func readCheckpoint(ctx context.Context, tx *sql.Tx, name string) (int64, bool, error) {
+	var position int64
+	err := tx.QueryRowContext(ctx,
+		`select event_id from projection_checkpoints where name = @name`,
+		sql.Named("name", name),
+	).Scan(&position)
+
+	if err == sql.ErrNoRows {
+		return 0, false, nil
+	}
+	if err != nil {
+		return 0, false, err
+	}
+
+	return position, true, nil
+}
+
+func writeCheckpoint(ctx context.Context, tx *sql.Tx, name string, position int64) error {
+	_, err := tx.ExecContext(ctx, `
+		insert into
+			projection_checkpoints (name, event_id)
+			values (@name, @event_id)
+		on conflict(name) do update set
+			event_id = @event_id`,
+		sql.Named("name", name),
+		sql.Named("event_id", position),
+	)
+	return err
+}
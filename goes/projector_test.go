@@ -0,0 +1,49 @@
+package goes
+
+import (
+	"context"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAsyncProjectionsAreCaughtUp(t *testing.T) {
+	ctx := context.Background()
+	store := NewSqliteStore(newTestDatabase(t))
+	require.NoError(t, store.Initialise(ctx))
+
+	inline := []int64{}
+	async := []int64{}
+
+	require.NoError(t, store.RegisterProjection("inline", StatelessProjection(func(ctx context.Context, event EventDescriptor) error {
+		inline = append(inline, event.Position)
+		return nil
+	})))
+	require.NoError(t, store.RegisterProjection("async", StatelessProjection(func(ctx context.Context, event EventDescriptor) error {
+		async = append(async, event.Position)
+		return nil
+	}), Async()))
+
+	c := newCounter(uuid.New())
+	for range 3 {
+		require.NoError(t, Apply(c.state, counterIncremented{By: 1}))
+	}
+	require.NoError(t, Save(ctx, store, c.state))
+
+	require.Equal(t, []int64{1, 2, 3}, inline)
+	require.Empty(t, async)
+
+	count, err := store.CatchUp(ctx, 2)
+	require.NoError(t, err)
+	require.Equal(t, 3, count)
+	require.Equal(t, []int64{1, 2, 3}, async)
+
+	require.NoError(t, Apply(c.state, counterIncremented{By: 1}))
+	require.NoError(t, Save(ctx, store, c.state))
+
+	count, err = store.CatchUp(ctx, 2)
+	require.NoError(t, err)
+	require.Equal(t, 1, count)
+	require.Equal(t, []int64{1, 2, 3, 4}, async)
+}
@@ -0,0 +1,54 @@
+package goes
+
+import (
+	"context"
+	"kirjasto/tracing"
+
+	"github.com/google/uuid"
+	"go.opentelemetry.io/otel/attribute"
+)
+
+// EventHandler can be implemented by projections which only handle some event types,
+// so that a dry run can report what a rebuild would actually process.
+type EventHandler interface {
+	Handles(eventType string) bool
+}
+
+type RebuildStats struct {
+	Events     int
+	Aggregates int
+}
+
+// DryRunRebuild reports how many events and aggregates a rebuild of the projection would
+// process, without changing anything.
+func DryRunRebuild(ctx context.Context, store Store, projection Projection) (RebuildStats, error) {
+	ctx, span := tr.Start(ctx, "dry_run_rebuild")
+	defer span.End()
+
+	handler, filtered := projection.(EventHandler)
+
+	stats := RebuildStats{}
+	aggregates := map[uuid.UUID]bool{}
+
+	for event, err := range store.AllEvents(ctx) {
+		if err != nil {
+			return stats, tracing.Error(span, err)
+		}
+
+		if filtered && !handler.Handles(event.EventType) {
+			continue
+		}
+
+		stats.Events++
+		aggregates[event.AggregateID] = true
+	}
+
+	stats.Aggregates = len(aggregates)
+
+	span.SetAttributes(
+		attribute.Int("event.count", stats.Events),
+		attribute.Int("aggregate.count", stats.Aggregates),
+	)
+
+	return stats, nil
+}
@@ -0,0 +1,56 @@
+package goes
+
+import (
+	"context"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/require"
+)
+
+type handlesOnly struct {
+	Projection
+	eventType string
+}
+
+func (h *handlesOnly) Handles(eventType string) bool {
+	return eventType == h.eventType
+}
+
+func TestDryRunRebuild(t *testing.T) {
+	ctx := context.Background()
+	store := NewMemoryStore(nil)
+
+	for range 2 {
+		c := newCounter(uuid.New())
+		require.NoError(t, Apply(c.state, counterIncremented{By: 1}))
+		require.NoError(t, Apply(c.state, counterIncremented{By: 1}))
+		require.NoError(t, Save(ctx, store, c.state))
+	}
+
+	all := StatelessProjection(func(ctx context.Context, event EventDescriptor) error { return nil })
+	stats, err := DryRunRebuild(ctx, store, all)
+	require.NoError(t, err)
+	require.Equal(t, RebuildStats{Events: 4, Aggregates: 2}, stats)
+
+	none := &handlesOnly{Projection: all, eventType: "somethingElse"}
+	stats, err = DryRunRebuild(ctx, store, none)
+	require.NoError(t, err)
+	require.Equal(t, RebuildStats{}, stats)
+}
+
+func TestProjectionsAreListedByName(t *testing.T) {
+	store := NewSqliteStore(nil)
+	noop := StatelessProjection(func(ctx context.Context, event EventDescriptor) error { return nil })
+
+	require.NoError(t, store.RegisterProjection("second", noop, Async()))
+	require.NoError(t, store.RegisterProjection("first", noop))
+	require.Error(t, store.RegisterProjection("first", noop))
+
+	projections := store.Projections()
+	require.Len(t, projections, 2)
+	require.Equal(t, "first", projections[0].Name)
+	require.False(t, projections[0].Async)
+	require.Equal(t, "second", projections[1].Name)
+	require.True(t, projections[1].Async)
+}
@@ -0,0 +1,50 @@
+package goes
+
+import (
+	"errors"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+var ErrNoSnapshot = errors.New("aggregate has no snapshot")
+
+// Snapshotter can be implemented by an aggregate to allow its state to be saved
+// and restored, rather than replaying every event on each load.
+//
+// Changing the snapshot's shape requires bumping SnapshotVersion, as snapshots
+// with a different version are ignored when loading.
+type Snapshotter interface {
+	SnapshotVersion() int
+	TakeSnapshot() (any, error)
+	RestoreSnapshot(data []byte) error
+}
+
+// SnapshotPolicy decides whether a new snapshot should be taken, given the
+// sequence of the last snapshot (-1 if there isn't one) and the current sequence.
+type SnapshotPolicy func(lastSnapshot int, sequence int) bool
+
+func EveryNEvents(n int) SnapshotPolicy {
+	return func(lastSnapshot int, sequence int) bool {
+		return sequence-lastSnapshot >= n
+	}
+}
+
+func NeverSnapshot() SnapshotPolicy {
+	return func(lastSnapshot int, sequence int) bool {
+		return false
+	}
+}
+
+type Snapshot struct {
+	AggregateID uuid.UUID
+	Sequence    int
+	Version     int
+	Timestamp   time.Time
+	Data        []byte
+}
+
+func EnableSnapshots(state *AggregateState, snapshotter Snapshotter, policy SnapshotPolicy) {
+	state.snapshotter = snapshotter
+	state.snapshotPolicy = policy
+}
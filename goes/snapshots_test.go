@@ -0,0 +1,132 @@
+package goes
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/require"
+)
+
+// versionedSnapshotter records whether the counter was restored from a snapshot
+type versionedSnapshotter struct {
+	c        *counter
+	version  int
+	restored bool
+}
+
+func (s *versionedSnapshotter) SnapshotVersion() int {
+	return s.version
+}
+
+func (s *versionedSnapshotter) TakeSnapshot() (any, error) {
+	return s.c.total, nil
+}
+
+func (s *versionedSnapshotter) RestoreSnapshot(data []byte) error {
+	s.restored = true
+	return json.Unmarshal(data, &s.c.total)
+}
+
+func snapshotStores() map[string]func(t *testing.T) Store {
+	return map[string]func(t *testing.T) Store{
+		"memory": func(t *testing.T) Store {
+			return NewMemoryStore(nil)
+		},
+		"sqlite": func(t *testing.T) Store {
+			store := NewSqliteStore(newTestDatabase(t))
+			require.NoError(t, store.Initialise(context.Background()))
+			return store
+		},
+	}
+}
+
+func newSnapshottedCounter(id uuid.UUID, version int) (*counter, *versionedSnapshotter) {
+	c := newCounter(id)
+	snapshotter := &versionedSnapshotter{c: c, version: version}
+	EnableSnapshots(c.state, snapshotter, EveryNEvents(2))
+
+	return c, snapshotter
+}
+
+func TestSnapshotsAreSavedAndLoaded(t *testing.T) {
+	for name, create := range snapshotStores() {
+		t.Run(name, func(t *testing.T) {
+			ctx := context.Background()
+			store := create(t)
+			id := uuid.New()
+
+			c, _ := newSnapshottedCounter(id, 1)
+			for range 3 {
+				require.NoError(t, Apply(c.state, counterIncremented{By: 2}))
+			}
+			require.NoError(t, Save(ctx, store, c.state))
+
+			snapshot, err := store.LoadSnapshot(ctx, id)
+			require.NoError(t, err)
+			require.Equal(t, 2, snapshot.Sequence)
+			require.Equal(t, 1, snapshot.Version)
+
+			loaded, snapshotter := newSnapshottedCounter(id, 1)
+			require.NoError(t, Load(ctx, store, loaded.state))
+			require.True(t, snapshotter.restored)
+			require.Equal(t, 6, loaded.total)
+			require.Equal(t, 2, Sequence(loaded.state))
+		})
+	}
+}
+
+func TestSnapshotsWithAnotherVersionAreIgnored(t *testing.T) {
+	for name, create := range snapshotStores() {
+		t.Run(name, func(t *testing.T) {
+			ctx := context.Background()
+			store := create(t)
+			id := uuid.New()
+
+			c, _ := newSnapshottedCounter(id, 1)
+			for range 3 {
+				require.NoError(t, Apply(c.state, counterIncremented{By: 2}))
+			}
+			require.NoError(t, Save(ctx, store, c.state))
+
+			loaded, snapshotter := newSnapshottedCounter(id, 2)
+			require.NoError(t, Load(ctx, store, loaded.state))
+			require.False(t, snapshotter.restored)
+			require.Equal(t, 6, loaded.total)
+			require.Equal(t, 2, Sequence(loaded.state))
+		})
+	}
+}
+
+func TestSnapshotsOnlyReplayLaterEvents(t *testing.T) {
+	for name, create := range snapshotStores() {
+		t.Run(name, func(t *testing.T) {
+			ctx := context.Background()
+			store := create(t)
+			id := uuid.New()
+
+			c := newCounter(id)
+			for range 3 {
+				require.NoError(t, Apply(c.state, counterIncremented{By: 1}))
+			}
+			require.NoError(t, Save(ctx, store, c.state))
+
+			// the snapshot's total doesn't match the events, to show which were replayed
+			require.NoError(t, store.SaveSnapshot(ctx, Snapshot{
+				AggregateID: id,
+				Sequence:    1,
+				Version:     1,
+				Timestamp:   time.Now().UTC(),
+				Data:        []byte("100"),
+			}))
+
+			loaded, snapshotter := newSnapshottedCounter(id, 1)
+			require.NoError(t, Load(ctx, store, loaded.state))
+			require.True(t, snapshotter.restored)
+			require.Equal(t, 101, loaded.total)
+			require.Equal(t, 2, Sequence(loaded.state))
+		})
+	}
+}
@@ -73,9 +73,13 @@ func AddProjectionHandler[TView any, TEvent any](p *SqlProjection[TView], projec
 		}
 	})
 
-	eventFactory[name] = func() any {
-		return new(TEvent)
-	}
+	// so that the event can be read even when no aggregate has registered it
+	registerEvent[TEvent]("", name, 1)
+}
+
+func (p *SqlProjection[TView]) Handles(eventType string) bool {
+	_, found := p.handlers[eventType]
+	return found
 }
 
 func (p *SqlProjection[TView]) Load(ctx context.Context, tx *sql.Tx) error {
@@ -190,3 +194,7 @@ func (p *SqlProjection[TView]) View(ctx context.Context, reader Readable, aggreg
 type Readable interface {
 	QueryRowContext(ctx context.Context, query string, args ...any) *sql.Row
 }
+
+type Queryable interface {
+	QueryContext(ctx context.Context, query string, args ...any) (*sql.Rows, error)
+}
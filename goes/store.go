@@ -2,21 +2,69 @@ package goes
 
 import (
 	"context"
+	"encoding/json"
+	"fmt"
+	"iter"
 	"kirjasto/tracing"
+	"time"
 
+	"github.com/google/uuid"
 	"go.opentelemetry.io/otel/attribute"
 )
 
-func Load(ctx context.Context, store *SqliteStore /* change to something more generic later*/, state *AggregateState) error {
+type Store interface {
+	Save(ctx context.Context, aggregateID uuid.UUID, sequence int, events []EventDescriptor) (SaveResult, error)
+	Load(ctx context.Context, aggregateID uuid.UUID, afterSequence int) iter.Seq2[EventDescriptor, error]
+	AllEvents(ctx context.Context) iter.Seq2[EventDescriptor, error]
+	Subscribe(ctx context.Context, fromPosition int64, filter EventFilter) iter.Seq2[EventDescriptor, error]
+
+	Events(ctx context.Context, filter EventFilter) iter.Seq2[EventDescriptor, error]
+	AggregateIDs(ctx context.Context, aggregateType string) ([]uuid.UUID, error)
+	Event(ctx context.Context, position int64) (EventDescriptor, error)
+	LastPosition(ctx context.Context) (int64, error)
+
+	ForgetSubject(ctx context.Context, subject string) error
+
+	SaveSnapshot(ctx context.Context, snapshot Snapshot) error
+	LoadSnapshot(ctx context.Context, aggregateID uuid.UUID) (*Snapshot, error)
+
+	RegisterProjection(name string, projection Projection, options ...ProjectionOption) error
+	Projections() []RegisteredProjection
+	Rebuild(ctx context.Context, projection Projection) error
+}
+
+// ErrConcurrencyConflict is returned when saving an aggregate which has had events
+// saved by someone else since it was loaded.
+type ErrConcurrencyConflict struct {
+	AggregateID    uuid.UUID
+	StoredSequence int
+	MemorySequence int
+}
+
+func (e *ErrConcurrencyConflict) Error() string {
+	return fmt.Sprintf("aggregate %s has new events in the store. stored: %v, memory: %v", e.AggregateID, e.StoredSequence, e.MemorySequence)
+}
+
+func Load(ctx context.Context, store Store, state *AggregateState) error {
 	ctx, span := tr.Start(ctx, "load")
 	defer span.End()
 
+	restored, err := restoreSnapshot(ctx, store, state)
+	if err != nil {
+		return tracing.Error(span, err)
+	}
+	span.SetAttributes(attribute.Bool("snapshot.restored", restored))
+
 	count := 0
-	for event, err := range store.Load(ctx, state.ID()) {
+	for event, err := range store.Load(ctx, state.ID(), state.sequence) {
 		if err != nil {
 			return tracing.Error(span, err)
 		}
 
+		if event.AggregateType != "" && event.AggregateType != state.aggregateType {
+			return tracing.Errorf(span, "aggregate %s is a %s, not a %s", state.ID(), event.AggregateType, state.aggregateType)
+		}
+
 		count++
 
 		if err := state.ReplayEvent(event); err != nil {
@@ -25,26 +73,119 @@ func Load(ctx context.Context, store *SqliteStore /* change to something more ge
 	}
 
 	span.SetAttributes(attribute.Int("event.count", count))
-	if count == 0 {
+	if count == 0 && !restored {
 		return ErrNotFound
 	}
 
 	return nil
 }
 
-func Save(ctx context.Context, store *SqliteStore, state *AggregateState) error {
+// LoadType returns every event saved by aggregates of the given type, in the order they
+// were saved.
+func LoadType(ctx context.Context, store Store, aggregateType string) iter.Seq2[EventDescriptor, error] {
+	return store.Events(ctx, EventFilter{AggregateTypes: []string{aggregateType}})
+}
+
+func restoreSnapshot(ctx context.Context, store Store, state *AggregateState) (bool, error) {
+	if state.snapshotter == nil {
+		return false, nil
+	}
+
+	snapshot, err := store.LoadSnapshot(ctx, state.ID())
+	if err != nil {
+		if err == ErrNoSnapshot {
+			return false, nil
+		}
+		return false, err
+	}
+
+	// an outdated snapshot is ignored, and the aggregate is replayed from the start instead
+	if snapshot.Version != state.snapshotter.SnapshotVersion() {
+		return false, nil
+	}
+
+	if err := state.snapshotter.RestoreSnapshot(snapshot.Data); err != nil {
+		return false, err
+	}
+
+	state.sequence = snapshot.Sequence
+	state.snapshotSequence = snapshot.Sequence
+
+	return true, nil
+}
+
+// Save writes the aggregate's pending events to the store.  The idempotency key can be
+// given as an option or with WithIdempotencyKey, and if it has been saved before the
+// events are discarded, and the aggregate takes the sequence of the original save.
+func Save(ctx context.Context, store Store, state *AggregateState, options ...SaveOption) error {
+	ctx, span := tr.Start(ctx, "save")
+	defer span.End()
 
 	pending := len(state.pendingEvents)
 	if pending == 0 {
 		return nil
 	}
 
-	if err := store.Save(ctx, state.ID(), Sequence(state), state.pendingEvents); err != nil {
+	for _, option := range options {
+		ctx = option(ctx)
+	}
+
+	addMetadata(ctx, state.pendingEvents)
+
+	result, err := store.Save(ctx, state.ID(), Sequence(state), state.pendingEvents)
+	if err != nil {
 		return err
 	}
 
+	span.SetAttributes(attribute.Bool("save.duplicate", result.Duplicate))
+	if result.Duplicate {
+		state.sequence = max(state.sequence, result.LastSequence)
+		state.pendingEvents = nil
+		return nil
+	}
+
 	state.sequence = state.pendingEvents[pending-1].Sequence
 	state.pendingEvents = nil
 
+	// the events are already saved, so a failed snapshot is recorded but not returned
+	if err := saveSnapshot(ctx, store, state); err != nil {
+		tracing.Error(span, err)
+	}
+
+	return nil
+}
+
+func saveSnapshot(ctx context.Context, store Store, state *AggregateState) error {
+	if state.snapshotter == nil || state.snapshotPolicy == nil {
+		return nil
+	}
+
+	if !state.snapshotPolicy(state.snapshotSequence, state.sequence) {
+		return nil
+	}
+
+	data, err := state.snapshotter.TakeSnapshot()
+	if err != nil {
+		return err
+	}
+
+	content, err := json.Marshal(data)
+	if err != nil {
+		return err
+	}
+
+	snapshot := Snapshot{
+		AggregateID: state.ID(),
+		Sequence:    state.sequence,
+		Version:     state.snapshotter.SnapshotVersion(),
+		Timestamp:   time.Now().UTC(),
+		Data:        content,
+	}
+
+	if err := store.SaveSnapshot(ctx, snapshot); err != nil {
+		return err
+	}
+
+	state.snapshotSequence = state.sequence
 	return nil
 }
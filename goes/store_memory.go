@@ -0,0 +1,416 @@
+package goes
+
+import (
+	"context"
+	"database/sql"
+	"iter"
+	"kirjasto/tracing"
+	"maps"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// NewMemoryStore creates a store which keeps all events in memory.  The db is only
+// used to give projections a transaction to work in, and can be nil if none of the
+// registered projections need one.
+func NewMemoryStore(db *sql.DB) *MemoryStore {
+	return &MemoryStore{
+		db:        db,
+		snapshots: map[uuid.UUID]Snapshot{},
+		keys:      map[string]memoryKey{},
+		saves:     map[savedKey]SaveResult{},
+		projections: Projectionist{
+			projections: map[string]*registeredProjection{},
+			asyncInline: true,
+			strictOnly:  true,
+		},
+	}
+}
+
+type MemoryStore struct {
+	lock sync.RWMutex
+
+	db          *sql.DB
+	events      []memoryEvent
+	snapshots   map[uuid.UUID]Snapshot
+	keys        map[string]memoryKey
+	saves       map[savedKey]SaveResult
+	projections Projectionist
+	notifier    notifier
+}
+
+// savedKey is an idempotency key, which is only used for saves to the same aggregate
+type savedKey struct {
+	aggregateID uuid.UUID
+	key         string
+}
+
+// events are stored serialised, so that loading behaves the same as the sqlite store
+type memoryEvent struct {
+	position      int64
+	aggregateID   uuid.UUID
+	aggregateType string
+	sequence      int
+	timestamp     time.Time
+	eventType     string
+	version       int
+	metadata      map[string]string
+	eventData     []byte
+	keyID         string
+}
+
+type memoryKey struct {
+	id  string
+	key []byte
+}
+
+// header is the descriptor without the event decoded, for filtering
+func (m memoryEvent) header() EventDescriptor {
+	return EventDescriptor{
+		Position:      m.position,
+		AggregateID:   m.aggregateID,
+		AggregateType: m.aggregateType,
+		Sequence:      m.sequence,
+		Timestamp:     m.timestamp,
+		EventType:     m.eventType,
+		Version:       m.version,
+		Metadata:      maps.Clone(m.metadata),
+	}
+}
+
+func (m memoryEvent) descriptor(key []byte) (EventDescriptor, error) {
+	e := m.header()
+	e.keyID = m.keyID
+	e.storedType = m.eventType
+	e.storedVersion = m.version
+	e.storedData = m.eventData
+
+	err := e.decode(m.eventData, key)
+	return e, err
+}
+
+// RegisterProjection adds a projection to the store.  The memory store has no background
+// projector, so projections registered with Async are run inline instead, and nowhere to
+// record failures, so projections registered with Isolate are strict.
+func (s *MemoryStore) RegisterProjection(name string, projection Projection, options ...ProjectionOption) error {
+	return s.projections.RegisterProjection(name, projection, options...)
+}
+
+func (s *MemoryStore) Projections() []RegisteredProjection {
+	return s.projections.Projections()
+}
+
+func (s *MemoryStore) Save(ctx context.Context, aggregateID uuid.UUID, sequence int, events []EventDescriptor) (SaveResult, error) {
+	ctx, span := tr.Start(ctx, "save")
+	defer span.End()
+
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	key := idempotencyKeyFrom(ctx)
+	if original, found := s.saves[savedKey{aggregateID, key}]; key != "" && found {
+		span.AddEvent("duplicate_save")
+		return original, nil
+	}
+
+	if stored := s.lastSequence(aggregateID); stored > sequence {
+		return SaveResult{}, tracing.Error(span, &ErrConcurrencyConflict{
+			AggregateID:    aggregateID,
+			StoredSequence: stored,
+			MemorySequence: sequence,
+		})
+	}
+
+	tx, err := s.begin(ctx)
+	if err != nil {
+		return SaveResult{}, tracing.Error(span, err)
+	}
+	if tx != nil {
+		defer tx.Rollback()
+	}
+
+	if err := s.projections.Load(ctx, tx); err != nil {
+		return SaveResult{}, tracing.Error(span, err)
+	}
+
+	written := make([]memoryEvent, 0, len(events))
+	for _, event := range events {
+		stored := memoryEvent{
+			position:      int64(len(s.events) + len(written) + 1),
+			aggregateID:   event.AggregateID,
+			aggregateType: event.AggregateType,
+			sequence:      event.Sequence,
+			timestamp:     event.Timestamp,
+			eventType:     event.storedType,
+			version:       event.storedVersion,
+			metadata:      maps.Clone(event.Metadata),
+			eventData:     event.storedData,
+			keyID:         event.keyID,
+		}
+
+		// events which already have a stored form, such as imported ones, are kept as
+		// they are, with their personal fields still encrypted
+		if event.storedData == nil {
+			if hasPersonalFields(event.Event) {
+				key, err := s.subjectKey(subjectOf(event))
+				if err != nil {
+					return SaveResult{}, tracing.Error(span, err)
+				}
+
+				stored.keyID = key.id
+				event.marshalled = nil
+				event.encryptionKey = key.key
+			}
+
+			eventJson, err := event.Marshal()
+			if err != nil {
+				return SaveResult{}, tracing.Error(span, err)
+			}
+
+			stored.eventType = event.EventType
+			stored.version = event.Version
+			stored.eventData = eventJson
+		}
+
+		written = append(written, stored)
+
+		event.Position = stored.position
+		if err := s.projections.Project(ctx, event); err != nil {
+			return SaveResult{}, tracing.Error(span, err)
+		}
+	}
+
+	if err := s.projections.Save(ctx, tx); err != nil {
+		return SaveResult{}, tracing.Error(span, err)
+	}
+
+	if tx != nil {
+		if err := tx.Commit(); err != nil {
+			return SaveResult{}, tracing.Error(span, err)
+		}
+	}
+
+	result := SaveResult{AggregateID: aggregateID, LastSequence: sequence}
+	if len(written) > 0 {
+		result.FirstPosition = written[0].position
+		result.LastPosition = written[len(written)-1].position
+		result.LastSequence = written[len(written)-1].sequence
+	}
+
+	if key != "" {
+		original := result
+		original.Duplicate = true
+		s.saves[savedKey{aggregateID, key}] = original
+	}
+
+	s.events = append(s.events, written...)
+	s.notifier.notify()
+
+	return result, nil
+}
+
+func (s *MemoryStore) lastSequence(aggregateID uuid.UUID) int {
+	last := -1
+	for _, e := range s.events {
+		if e.aggregateID == aggregateID && e.sequence > last {
+			last = e.sequence
+		}
+	}
+	return last
+}
+
+// subjectKey finds or creates the subject's key, and must be called with the lock held
+func (s *MemoryStore) subjectKey(subject string) (memoryKey, error) {
+	if key, found := s.keys[subject]; found {
+		return key, nil
+	}
+
+	key, err := newEncryptionKey()
+	if err != nil {
+		return memoryKey{}, err
+	}
+
+	s.keys[subject] = memoryKey{id: uuid.NewString(), key: key}
+	return s.keys[subject], nil
+}
+
+func (s *MemoryStore) keyByID(id string) []byte {
+	for _, key := range s.keys {
+		if key.id == id {
+			return key.key
+		}
+	}
+	return nil
+}
+
+// ForgetSubject deletes the subject's key, so that their personal fields are redacted
+// from then on.  Projections need rebuilding to remove the data from views.
+func (s *MemoryStore) ForgetSubject(ctx context.Context, subject string) error {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	delete(s.keys, subject)
+	return nil
+}
+
+func (s *MemoryStore) begin(ctx context.Context) (*sql.Tx, error) {
+	if s.db == nil {
+		return nil, nil
+	}
+	return s.db.BeginTx(ctx, nil)
+}
+
+// Load returns the aggregate's events which have a sequence greater than afterSequence.
+// Pass -1 to load all events.
+func (s *MemoryStore) Load(ctx context.Context, aggregateID uuid.UUID, afterSequence int) iter.Seq2[EventDescriptor, error] {
+	return s.iterate(func(e memoryEvent) bool {
+		return e.aggregateID == aggregateID && e.sequence > afterSequence
+	})
+}
+
+func (s *MemoryStore) AllEvents(ctx context.Context) iter.Seq2[EventDescriptor, error] {
+	return s.iterate(func(e memoryEvent) bool {
+		return true
+	})
+}
+
+// Subscribe streams all events after fromPosition which match the filter, and then waits
+// for new events to be saved, until the context is cancelled.
+func (s *MemoryStore) Subscribe(ctx context.Context, fromPosition int64, filter EventFilter) iter.Seq2[EventDescriptor, error] {
+	return subscribe(ctx, &s.notifier, fromPosition, func(position int64) iter.Seq2[EventDescriptor, error] {
+		return s.iterate(func(e memoryEvent) bool {
+			return e.position > position && filter.Matches(e.header())
+		})
+	})
+}
+
+// Events returns all events which match the filter, in the order they were saved.
+func (s *MemoryStore) Events(ctx context.Context, filter EventFilter) iter.Seq2[EventDescriptor, error] {
+	return s.iterate(func(e memoryEvent) bool {
+		return filter.Matches(e.header())
+	})
+}
+
+// Event returns the event at the given position, or ErrNoEvent if there isn't one.
+func (s *MemoryStore) Event(ctx context.Context, position int64) (EventDescriptor, error) {
+	for event, err := range s.iterate(func(e memoryEvent) bool { return e.position == position }) {
+		return event, err
+	}
+
+	return EventDescriptor{}, ErrNoEvent
+}
+
+// AggregateIDs lists the aggregates of the given type, in the order they were created.
+func (s *MemoryStore) AggregateIDs(ctx context.Context, aggregateType string) ([]uuid.UUID, error) {
+	s.lock.RLock()
+	defer s.lock.RUnlock()
+
+	ids := []uuid.UUID{}
+	for _, e := range s.events {
+		if e.aggregateType == aggregateType && !slices.Contains(ids, e.aggregateID) {
+			ids = append(ids, e.aggregateID)
+		}
+	}
+
+	return ids, nil
+}
+
+// LastPosition returns the position of the most recently saved event, or 0 if there are none.
+func (s *MemoryStore) LastPosition(ctx context.Context) (int64, error) {
+	s.lock.RLock()
+	defer s.lock.RUnlock()
+
+	return int64(len(s.events)), nil
+}
+
+func (s *MemoryStore) iterate(filter func(e memoryEvent) bool) iter.Seq2[EventDescriptor, error] {
+	return func(yield func(EventDescriptor, error) bool) {
+
+		s.lock.RLock()
+		matching := make([]memoryEvent, 0, len(s.events))
+		keys := make([][]byte, 0, len(s.events))
+		for _, e := range s.events {
+			if filter(e) {
+				matching = append(matching, e)
+				keys = append(keys, s.keyByID(e.keyID))
+			}
+		}
+		s.lock.RUnlock()
+
+		for i, e := range matching {
+			if !yield(e.descriptor(keys[i])) {
+				return
+			}
+		}
+	}
+}
+
+func (s *MemoryStore) SaveSnapshot(ctx context.Context, snapshot Snapshot) error {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	if existing, found := s.snapshots[snapshot.AggregateID]; found && existing.Sequence > snapshot.Sequence {
+		return nil
+	}
+
+	s.snapshots[snapshot.AggregateID] = snapshot
+	return nil
+}
+
+func (s *MemoryStore) LoadSnapshot(ctx context.Context, aggregateID uuid.UUID) (*Snapshot, error) {
+	s.lock.RLock()
+	defer s.lock.RUnlock()
+
+	snapshot, found := s.snapshots[aggregateID]
+	if !found {
+		return nil, ErrNoSnapshot
+	}
+
+	return &snapshot, nil
+}
+
+func (s *MemoryStore) Rebuild(ctx context.Context, projection Projection) error {
+	ctx, span := tr.Start(ctx, "rebuild")
+	defer span.End()
+
+	tx, err := s.begin(ctx)
+	if err != nil {
+		return tracing.Error(span, err)
+	}
+	if tx != nil {
+		defer tx.Rollback()
+	}
+
+	if err := projection.Load(ctx, tx); err != nil {
+		return tracing.Error(span, err)
+	}
+
+	if err := projection.Wipe(ctx); err != nil {
+		return tracing.Error(span, err)
+	}
+
+	for event, err := range s.AllEvents(ctx) {
+		if err != nil {
+			return tracing.Error(span, err)
+		}
+
+		if err := projection.Project(ctx, event); err != nil {
+			return tracing.Error(span, err)
+		}
+	}
+
+	if err := projection.Save(ctx, tx); err != nil {
+		return tracing.Error(span, err)
+	}
+
+	if tx != nil {
+		if err := tx.Commit(); err != nil {
+			return tracing.Error(span, err)
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,142 @@
+package goes
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/require"
+)
+
+type counterIncremented struct {
+	By int
+}
+
+type counter struct {
+	state *AggregateState
+	total int
+}
+
+func newCounter(id uuid.UUID) *counter {
+	c := &counter{state: NewAggregateState("counter")}
+	SetID(c.state, id)
+
+	Register(c.state, func(e counterIncremented) {
+		c.total += e.By
+	})
+
+	return c
+}
+
+func TestMemoryStoreRoundTrip(t *testing.T) {
+	ctx := context.Background()
+	store := NewMemoryStore(nil)
+	id := uuid.New()
+
+	c := newCounter(id)
+	require.NoError(t, Apply(c.state, counterIncremented{By: 2}))
+	require.NoError(t, Apply(c.state, counterIncremented{By: 3}))
+	require.NoError(t, Save(ctx, store, c.state))
+
+	loaded := newCounter(id)
+	require.NoError(t, Load(ctx, store, loaded.state))
+	require.Equal(t, 5, loaded.total)
+	require.Equal(t, 1, Sequence(loaded.state))
+}
+
+func TestMemoryStoreMissingAggregate(t *testing.T) {
+	store := NewMemoryStore(nil)
+
+	err := Load(context.Background(), store, newCounter(uuid.New()).state)
+	require.ErrorIs(t, err, ErrNotFound)
+}
+
+func TestMemoryStoreConcurrentWrites(t *testing.T) {
+	ctx := context.Background()
+	store := NewMemoryStore(nil)
+	id := uuid.New()
+
+	first := newCounter(id)
+	second := newCounter(id)
+
+	require.NoError(t, Apply(first.state, counterIncremented{By: 1}))
+	require.NoError(t, Apply(second.state, counterIncremented{By: 1}))
+
+	require.NoError(t, Save(ctx, store, first.state))
+	require.Error(t, Save(ctx, store, second.state))
+}
+
+func TestMemoryStoreProjectionFailureRollsBack(t *testing.T) {
+	ctx := context.Background()
+	store := NewMemoryStore(nil)
+	id := uuid.New()
+
+	require.NoError(t, store.RegisterProjection("failing", StatelessProjection(func(ctx context.Context, event EventDescriptor) error {
+		return context.Canceled
+	})))
+
+	c := newCounter(id)
+	require.NoError(t, Apply(c.state, counterIncremented{By: 1}))
+	require.Error(t, Save(ctx, store, c.state))
+
+	require.ErrorIs(t, Load(ctx, store, newCounter(id).state), ErrNotFound)
+}
+
+func TestMemoryStoreRunsIsolatedProjections(t *testing.T) {
+	ctx := context.Background()
+	store := NewMemoryStore(nil)
+
+	seen := 0
+	require.NoError(t, store.RegisterProjection("isolated", StatelessProjection(func(ctx context.Context, event EventDescriptor) error {
+		seen++
+		return nil
+	}), Isolate()))
+
+	c := newCounter(uuid.New())
+	require.NoError(t, Apply(c.state, counterIncremented{By: 1}))
+	require.NoError(t, Apply(c.state, counterIncremented{By: 2}))
+	require.NoError(t, Save(ctx, store, c.state))
+
+	require.Equal(t, 2, seen)
+}
+
+func TestMemoryStoreSnapshots(t *testing.T) {
+	ctx := context.Background()
+	store := NewMemoryStore(nil)
+	id := uuid.New()
+
+	c := newCounter(id)
+	EnableSnapshots(c.state, &counterSnapshotter{c}, EveryNEvents(2))
+
+	for range 3 {
+		require.NoError(t, Apply(c.state, counterIncremented{By: 1}))
+	}
+	require.NoError(t, Save(ctx, store, c.state))
+
+	snapshot, err := store.LoadSnapshot(ctx, id)
+	require.NoError(t, err)
+	require.Equal(t, 2, snapshot.Sequence)
+
+	loaded := newCounter(id)
+	EnableSnapshots(loaded.state, &counterSnapshotter{loaded}, EveryNEvents(2))
+	require.NoError(t, Load(ctx, store, loaded.state))
+	require.Equal(t, 3, loaded.total)
+	require.Equal(t, 2, Sequence(loaded.state))
+}
+
+type counterSnapshotter struct {
+	c *counter
+}
+
+func (s *counterSnapshotter) SnapshotVersion() int {
+	return 1
+}
+
+func (s *counterSnapshotter) TakeSnapshot() (any, error) {
+	return s.c.total, nil
+}
+
+func (s *counterSnapshotter) RestoreSnapshot(data []byte) error {
+	return json.Unmarshal(data, &s.c.total)
+}
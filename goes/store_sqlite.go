@@ -4,7 +4,6 @@ import (
 	"context"
 	"database/sql"
 	"errors"
-	"fmt"
 	"iter"
 	"kirjasto/tracing"
 
@@ -14,20 +13,43 @@ import (
 )
 
 var ErrNotFound = errors.New("aggregate does not exist")
+var ErrNoEvent = errors.New("event does not exist")
 var tr = otel.Tracer("goes")
 
-func NewSqliteStore(db *sql.DB) *SqliteStore {
-	return &SqliteStore{
+type SqliteOption func(s *SqliteStore)
+
+// WithStalePolicy sets what Initialise does when a registered projection's version has changed.
+func WithStalePolicy(policy StalePolicy) SqliteOption {
+	return func(s *SqliteStore) {
+		s.stalePolicy = policy
+	}
+}
+
+func NewSqliteStore(db *sql.DB, options ...SqliteOption) *SqliteStore {
+	store := &SqliteStore{
 		db: db,
 		projections: Projectionist{
-			projections: map[string]Projection{},
+			projections: map[string]*registeredProjection{},
 		},
+		stalePolicy: RebuildStale,
 	}
+
+	for _, option := range options {
+		option(store)
+	}
+
+	return store
 }
 
 type SqliteStore struct {
 	db          *sql.DB
 	projections Projectionist
+	notifier    notifier
+	stalePolicy StalePolicy
+	hashChain   bool
+
+	// outbox is the event types queued for each destination, see WithOutbox
+	outbox map[string][]string
 }
 
 func (s *SqliteStore) Initialise(ctx context.Context) error {
@@ -45,6 +67,69 @@ CREATE TABLE IF NOT EXISTS events(
 	constraint aggregate_sequence unique(aggregate_id, sequence) on conflict rollback
 );
 
+create table if not exists snapshots(
+	aggregate_id text not null,
+	sequence integer not null,
+	version integer not null,
+	timestamp timestamp not null,
+	snapshot_data text not null,
+	primary key(aggregate_id, sequence)
+);
+
+create table if not exists projection_checkpoints(
+	name text primary key,
+	event_id integer not null
+);
+
+create table if not exists projection_versions(
+	name text primary key,
+	version integer not null
+);
+
+create table if not exists projection_failures(
+	failure_id integer primary key autoincrement,
+	name text not null,
+	event_id integer not null,
+	event_type text not null,
+	error text not null,
+	failed_at timestamp not null
+);
+
+create table if not exists encryption_keys(
+	key_id text primary key,
+	subject text not null unique,
+	key_data blob not null
+);
+
+create table if not exists idempotency_keys(
+	idempotency_key text not null,
+	aggregate_id text not null,
+	first_event_id integer not null,
+	last_event_id integer not null,
+	last_sequence integer not null,
+	created_at timestamp not null,
+	primary key(aggregate_id, idempotency_key)
+);
+
+create table if not exists outbox(
+	outbox_id integer primary key autoincrement,
+	destination text not null,
+	event_id integer not null,
+	created_at timestamp not null,
+	attempts integer not null default 0,
+	next_attempt_at timestamp not null,
+	delivered_at timestamp,
+	abandoned_at timestamp
+);
+
+create table if not exists outbox_attempts(
+	attempt_id integer primary key autoincrement,
+	outbox_id integer not null,
+	attempted_at timestamp not null,
+	succeeded boolean not null,
+	detail text not null
+);
+
 create table if not exists auto_projections(
 	aggregate_id text primary key,
 	view_type text not null,
@@ -55,54 +140,116 @@ create table if not exists auto_projections(
 		return tracing.Error(span, err)
 	}
 
+	if err := addMissingColumns(ctx, s.db, "events", eventColumns); err != nil {
+		return tracing.Error(span, err)
+	}
+
+	if err := scopeIdempotencyKeys(ctx, s.db); err != nil {
+		return tracing.Error(span, err)
+	}
+
+	// the index needs the aggregate_type column, which older databases have only just gained
+	if _, err := s.db.ExecContext(ctx, "create index if not exists events_aggregate_type on events(aggregate_type, aggregate_id)"); err != nil {
+		return tracing.Error(span, err)
+	}
+
+	// projections must be registered before initialising for their versions to be checked
+	if err := s.checkProjectionVersions(ctx); err != nil {
+		return tracing.Error(span, err)
+	}
+
 	return nil
 }
 
-func (s *SqliteStore) RegisterProjection(name string, projection Projection) error {
-	return s.projections.RegisterProjection(name, projection)
+func (s *SqliteStore) RegisterProjection(name string, projection Projection, options ...ProjectionOption) error {
+	return s.projections.RegisterProjection(name, projection, options...)
 }
 
-func (s *SqliteStore) Save(ctx context.Context, aggregateID uuid.UUID, sequence int, events []EventDescriptor) error {
+func (s *SqliteStore) Projections() []RegisteredProjection {
+	return s.projections.Projections()
+}
+
+// Save writes the events for the aggregate, along with the strict projections' changes,
+// in a single transaction.  If the context has an idempotency key which has already been
+// saved, nothing is written and the original save's result is returned.
+func (s *SqliteStore) Save(ctx context.Context, aggregateID uuid.UUID, sequence int, events []EventDescriptor) (SaveResult, error) {
 	ctx, span := tr.Start(ctx, "save")
 	defer span.End()
 
 	tx, err := s.db.BeginTx(ctx, nil)
 	if err != nil {
-		return tracing.Error(span, err)
+		return SaveResult{}, tracing.Error(span, err)
 	}
 	defer tx.Rollback()
 
+	key := idempotencyKeyFrom(ctx)
+	if key != "" {
+		original, found, err := readIdempotencyKey(ctx, tx, aggregateID, key)
+		if err != nil {
+			return SaveResult{}, tracing.Error(span, err)
+		}
+		if found {
+			span.AddEvent("duplicate_save")
+			return original, nil
+		}
+	}
+
 	if err := validateSequence(ctx, tx, aggregateID, sequence); err != nil {
-		return tracing.Error(span, err)
+		return SaveResult{}, tracing.Error(span, err)
 	}
 
 	if err := s.projections.Load(ctx, tx); err != nil {
-		return tracing.Error(span, err)
+		return SaveResult{}, tracing.Error(span, err)
 	}
 
-	writer, err := newEventWriter(ctx, tx)
+	writer, err := newEventWriter(ctx, tx, s.hashChain)
 	if err != nil {
-		return tracing.Error(span, err)
+		return SaveResult{}, tracing.Error(span, err)
 	}
 
+	written := make([]EventDescriptor, 0, len(events))
 	for _, event := range events {
-		if err := writer.Write(ctx, event); err != nil {
-			return err
+		if event.Position, err = writer.Write(ctx, event); err != nil {
+			return SaveResult{}, tracing.Error(span, err)
 		}
 		if err := s.projections.Project(ctx, event); err != nil {
-			return tracing.Error(span, err)
+			return SaveResult{}, tracing.Error(span, err)
 		}
+		written = append(written, event)
 	}
 
 	if err := s.projections.Save(ctx, tx); err != nil {
-		return tracing.Error(span, err)
+		return SaveResult{}, tracing.Error(span, err)
+	}
+
+	if err := s.projectIsolated(ctx, tx, written); err != nil {
+		return SaveResult{}, tracing.Error(span, err)
+	}
+
+	if err := s.fillOutbox(ctx, tx, written); err != nil {
+		return SaveResult{}, tracing.Error(span, err)
+	}
+
+	result := SaveResult{AggregateID: aggregateID, LastSequence: sequence}
+	if len(written) > 0 {
+		result.FirstPosition = written[0].Position
+		result.LastPosition = written[len(written)-1].Position
+		result.LastSequence = written[len(written)-1].Sequence
+	}
+
+	if key != "" {
+		if err := writeIdempotencyKey(ctx, tx, key, result); err != nil {
+			return SaveResult{}, tracing.Error(span, err)
+		}
 	}
 
 	if err := tx.Commit(); err != nil {
-		return tracing.Error(span, err)
+		return SaveResult{}, tracing.Error(span, err)
 	}
 
-	return nil
+	s.notifier.notify()
+
+	return result, nil
 }
 
 func validateSequence(ctx context.Context, tx *sql.Tx, aggregateID uuid.UUID, memorySequence int) error {
@@ -115,93 +262,194 @@ func validateSequence(ctx context.Context, tx *sql.Tx, aggregateID uuid.UUID, me
 	}
 
 	if dbSequence.Valid && dbSequence.Int64 > int64(memorySequence) {
-		return fmt.Errorf("aggregate has new events in the database. db: %v, memory: %v", dbSequence, memorySequence)
+		return &ErrConcurrencyConflict{
+			AggregateID:    aggregateID,
+			StoredSequence: int(dbSequence.Int64),
+			MemorySequence: memorySequence,
+		}
 	}
 
 	return nil
 }
 
-func (s *SqliteStore) Load(ctx context.Context, aggregateID uuid.UUID) iter.Seq2[EventDescriptor, error] {
-	return func(yield func(EventDescriptor, error) bool) {
+// Load returns the aggregate's events which have a sequence greater than afterSequence.
+// Pass -1 to load all events.
+func (s *SqliteStore) Load(ctx context.Context, aggregateID uuid.UUID, afterSequence int) iter.Seq2[EventDescriptor, error] {
+	return queryEvents(ctx, s.db, eventSelect+`
+		where aggregate_id = @aggregate_id
+		and sequence > @after_sequence
+		order by sequence asc`,
+		sql.Named("aggregate_id", aggregateID.String()),
+		sql.Named("after_sequence", afterSequence),
+	)
+}
 
-		rows, err := s.db.QueryContext(ctx, `
-			select sequence, timestamp, event_type, event_data
-			from events
-			where aggregate_id = @aggregate_id
-			order by sequence asc
-		`, sql.Named("aggregate_id", aggregateID.String()))
-		if err != nil {
-			if !yield(EventDescriptor{}, err) {
-				return
-			}
-		}
-		defer rows.Close()
+func (s *SqliteStore) SaveSnapshot(ctx context.Context, snapshot Snapshot) error {
+	ctx, span := tr.Start(ctx, "save_snapshot")
+	defer span.End()
 
-		for rows.Next() {
+	_, err := s.db.ExecContext(ctx, `
+		insert into
+			snapshots (aggregate_id, sequence, version, timestamp, snapshot_data)
+			values (@aggregate_id, @sequence, @version, @timestamp, @snapshot_data)
+		on conflict(aggregate_id, sequence) do update set
+			version = @version,
+			timestamp = @timestamp,
+			snapshot_data = @snapshot_data`,
+		sql.Named("aggregate_id", snapshot.AggregateID.String()),
+		sql.Named("sequence", snapshot.Sequence),
+		sql.Named("version", snapshot.Version),
+		sql.Named("timestamp", snapshot.Timestamp),
+		sql.Named("snapshot_data", snapshot.Data),
+	)
+	if err != nil {
+		return tracing.Error(span, err)
+	}
 
-			e := EventDescriptor{
-				AggregateID: aggregateID,
-			}
+	return nil
+}
 
-			var eventJson []byte
+func (s *SqliteStore) LoadSnapshot(ctx context.Context, aggregateID uuid.UUID) (*Snapshot, error) {
+	ctx, span := tr.Start(ctx, "load_snapshot")
+	defer span.End()
 
-			if err := rows.Scan(&e.Sequence, &e.Timestamp, &e.EventType, &eventJson); err != nil {
-				if !yield(e, err) {
-					return
-				}
-			}
+	row := s.db.QueryRowContext(ctx, `
+		select sequence, version, timestamp, snapshot_data
+		from snapshots
+		where aggregate_id = @aggregate_id
+		order by sequence desc
+		limit 1`,
+		sql.Named("aggregate_id", aggregateID.String()),
+	)
+
+	snapshot := &Snapshot{AggregateID: aggregateID}
+	if err := row.Scan(&snapshot.Sequence, &snapshot.Version, &snapshot.Timestamp, &snapshot.Data); err != nil {
+		if err == sql.ErrNoRows {
+			return nil, ErrNoSnapshot
+		}
+		return nil, tracing.Error(span, err)
+	}
 
-			if e.Event, err = eventFromJson(e.EventType, eventJson); err != nil {
-				if !yield(e, err) {
-					return
-				}
-			}
+	return snapshot, nil
+}
 
-			if !yield(e, nil) {
-				return
-			}
-		}
+// ForgetSubject deletes the subject's key, so that their personal fields are redacted
+// from then on.  Projections need rebuilding to remove the data from views.
+func (s *SqliteStore) ForgetSubject(ctx context.Context, subject string) error {
+	ctx, span := tr.Start(ctx, "forget_subject")
+	defer span.End()
 
+	_, err := s.db.ExecContext(ctx,
+		"delete from encryption_keys where subject = @subject",
+		sql.Named("subject", subject),
+	)
+	if err != nil {
+		return tracing.Error(span, err)
 	}
+
+	return nil
+}
+
+func (s *SqliteStore) AllEvents(ctx context.Context) iter.Seq2[EventDescriptor, error] {
+	return s.allEvents(ctx, s.db)
 }
 
-func (s *SqliteStore) allEvents(ctx context.Context, tx *sql.Tx) iter.Seq2[EventDescriptor, error] {
-	return func(yield func(EventDescriptor, error) bool) {
+// Subscribe streams all events after fromPosition which match the filter, and then waits
+// for new events to be saved, until the context is cancelled.
+func (s *SqliteStore) Subscribe(ctx context.Context, fromPosition int64, filter EventFilter) iter.Seq2[EventDescriptor, error] {
+	conditions, args := filter.where()
 
-		rows, err := tx.QueryContext(ctx, `
-			select aggregate_id, sequence, timestamp, event_type, event_data
-			from events
+	return subscribe(ctx, &s.notifier, fromPosition, func(position int64) iter.Seq2[EventDescriptor, error] {
+		return queryEvents(ctx, s.db, eventSelect+`
+			where event_id > @position`+conditions+`
 			order by event_id asc
-		`)
-		if err != nil {
-			if !yield(EventDescriptor{}, err) {
-				return
-			}
-		}
-		defer rows.Close()
+			limit @limit`,
+			append(args,
+				sql.Named("position", position),
+				sql.Named("limit", DefaultBatchSize),
+			)...,
+		)
+	})
+}
 
-		for rows.Next() {
+// Events returns all events which match the filter, in the order they were saved.
+func (s *SqliteStore) Events(ctx context.Context, filter EventFilter) iter.Seq2[EventDescriptor, error] {
+	conditions, args := filter.where()
 
-			e := EventDescriptor{}
+	return queryEvents(ctx, s.db, eventSelect+`
+		where 1 = 1`+conditions+`
+		order by event_id asc`,
+		args...,
+	)
+}
 
-			var eventJson []byte
-			if err := rows.Scan(&e.AggregateID, &e.Sequence, &e.Timestamp, &e.EventType, &eventJson); err != nil {
-				if !yield(e, err) {
-					return
-				}
-			}
+// Event returns the event at the given position, or ErrNoEvent if there isn't one.
+func (s *SqliteStore) Event(ctx context.Context, position int64) (EventDescriptor, error) {
+	for event, err := range queryEvents(ctx, s.db, eventSelect+` where event_id = @position`, sql.Named("position", position)) {
+		return event, err
+	}
 
-			if e.Event, err = eventFromJson(e.EventType, eventJson); err != nil {
-				if !yield(e, err) {
-					return
-				}
-			}
+	return EventDescriptor{}, ErrNoEvent
+}
 
-			if !yield(e, nil) {
-				return
-			}
+// AggregateIDs lists the aggregates of the given type, in the order they were created.
+func (s *SqliteStore) AggregateIDs(ctx context.Context, aggregateType string) ([]uuid.UUID, error) {
+	ctx, span := tr.Start(ctx, "aggregate_ids")
+	defer span.End()
+
+	rows, err := s.db.QueryContext(ctx, `
+		select aggregate_id
+		from events
+		where aggregate_type = @aggregate_type
+		group by aggregate_id
+		order by min(event_id) asc`,
+		sql.Named("aggregate_type", aggregateType),
+	)
+	if err != nil {
+		return nil, tracing.Error(span, err)
+	}
+	defer rows.Close()
+
+	ids := []uuid.UUID{}
+	for rows.Next() {
+		var id uuid.UUID
+		if err := rows.Scan(&id); err != nil {
+			return nil, tracing.Error(span, err)
 		}
+		ids = append(ids, id)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, tracing.Error(span, err)
+	}
+
+	return ids, nil
+}
+
+// LastPosition returns the position of the most recently saved event, or 0 if there are none.
+func (s *SqliteStore) LastPosition(ctx context.Context) (int64, error) {
+	var position sql.NullInt64
+	if err := s.db.QueryRowContext(ctx, "select max(event_id) from events").Scan(&position); err != nil {
+		return 0, err
 	}
+
+	return position.Int64, nil
+}
+
+func (s *SqliteStore) allEvents(ctx context.Context, reader Queryable) iter.Seq2[EventDescriptor, error] {
+	return eventsAfter(ctx, reader, 0, -1)
+}
+
+// eventsAfter reads events with a position greater than the one given, with a limit of
+// -1 meaning all events.
+func eventsAfter(ctx context.Context, reader Queryable, position int64, limit int) iter.Seq2[EventDescriptor, error] {
+	return queryEvents(ctx, reader, eventSelect+`
+		where event_id > @position
+		order by event_id asc
+		limit @limit`,
+		sql.Named("position", position),
+		sql.Named("limit", limit),
+	)
 }
 
 func (s *SqliteStore) Rebuild(ctx context.Context, projection Projection) error {
@@ -222,6 +470,7 @@ func (s *SqliteStore) Rebuild(ctx context.Context, projection Projection) error
 		return tracing.Error(span, err)
 	}
 
+	position := int64(0)
 	for event, err := range s.allEvents(ctx, tx) {
 		if err != nil {
 			return tracing.Error(span, err)
@@ -230,12 +479,33 @@ func (s *SqliteStore) Rebuild(ctx context.Context, projection Projection) error
 		if err := projection.Project(ctx, event); err != nil {
 			return tracing.Error(span, err)
 		}
+		position = event.Position
 	}
 
 	if err := projection.Save(ctx, tx); err != nil {
 		return tracing.Error(span, err)
 	}
 
+	if name, found := s.projections.nameOf(projection); found {
+		// async projections are now up to date, so the projector shouldn't replay anything
+		if s.projections.isAsync(s.projections.projections[name]) {
+			if err := writeCheckpoint(ctx, tx, name, position); err != nil {
+				return tracing.Error(span, err)
+			}
+		}
+
+		// the rebuild has processed every event, including any which had failed
+		if err := clearFailures(ctx, tx, name); err != nil {
+			return tracing.Error(span, err)
+		}
+
+		if versioned, ok := projection.(VersionedProjection); ok {
+			if err := writeProjectionVersion(ctx, tx, name, versioned.Version()); err != nil {
+				return tracing.Error(span, err)
+			}
+		}
+	}
+
 	if err := tx.Commit(); err != nil {
 		return tracing.Error(span, err)
 	}
@@ -0,0 +1,124 @@
+package goes
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"sync"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/require"
+)
+
+func newTestDatabase(t *testing.T) *sql.DB {
+	db, err := sql.Open("sqlite3", ":memory:")
+	require.NoError(t, err)
+	db.SetMaxOpenConns(1)
+	t.Cleanup(func() { db.Close() })
+
+	return db
+}
+
+func TestSqliteStoreMigratesOldEventsTable(t *testing.T) {
+	ctx := context.Background()
+	db := newTestDatabase(t)
+	id := uuid.New()
+
+	_, err := db.Exec(`
+		create table events(
+			event_id integer primary key autoincrement,
+			aggregate_id text not null,
+			sequence integer not null,
+			timestamp timestamp not null,
+			event_type text not null,
+			event_data text not null
+		);
+		insert into events (aggregate_id, sequence, timestamp, event_type, event_data)
+		values (?, 0, current_timestamp, 'counterIncremented', '{"By":4}')`, id)
+	require.NoError(t, err)
+
+	store := NewSqliteStore(db)
+	require.NoError(t, store.Initialise(ctx))
+
+	c := newCounter(id)
+	require.NoError(t, Load(ctx, store, c.state))
+	require.Equal(t, 4, c.total)
+
+	require.NoError(t, Apply(c.state, counterIncremented{By: 1}))
+	require.NoError(t, Save(ctx, store, c.state))
+}
+
+func TestSqliteStoreSavesMetadata(t *testing.T) {
+	ctx := context.Background()
+	store := NewSqliteStore(newTestDatabase(t))
+	require.NoError(t, store.Initialise(ctx))
+
+	var projected map[string]string
+	require.NoError(t, store.RegisterProjection("metadata", StatelessProjection(func(ctx context.Context, event EventDescriptor) error {
+		projected = event.Metadata
+		return nil
+	})))
+
+	ctx = WithCommand(ctx, "library add")
+	ctx = WithActor(ctx, "someone")
+
+	c := newCounter(uuid.New())
+	require.NoError(t, Apply(c.state, counterIncremented{By: 1}))
+	require.NoError(t, Save(ctx, store, c.state))
+
+	expected := map[string]string{
+		MetadataCommand: "library add",
+		MetadataActor:   "someone",
+	}
+	require.Equal(t, expected, projected)
+
+	for event, err := range store.Load(ctx, c.state.ID(), -1) {
+		require.NoError(t, err)
+		require.Equal(t, expected, event.Metadata)
+	}
+}
+
+func TestSqliteStoreConcurrentLoadAndSave(t *testing.T) {
+	ctx := context.Background()
+	store := NewSqliteStore(newTestDatabase(t))
+	require.NoError(t, store.Initialise(ctx))
+
+	// each aggregate registers its events as it is created, while the others are
+	// being read
+	errs := make(chan error, 10)
+	wg := sync.WaitGroup{}
+	for range 10 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			id := uuid.New()
+			c := newCounter(id)
+			if err := Apply(c.state, counterIncremented{By: 2}); err != nil {
+				errs <- err
+				return
+			}
+			if err := Save(ctx, store, c.state); err != nil {
+				errs <- err
+				return
+			}
+
+			loaded := newCounter(id)
+			if err := Load(ctx, store, loaded.state); err != nil {
+				errs <- err
+				return
+			}
+			if loaded.total != 2 {
+				errs <- fmt.Errorf("expected a total of 2, but got %d", loaded.total)
+			}
+		}()
+	}
+
+	wg.Wait()
+	close(errs)
+
+	for err := range errs {
+		require.NoError(t, err)
+	}
+}
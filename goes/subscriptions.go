@@ -0,0 +1,190 @@
+package goes
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"iter"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// PollInterval is how often a subscription checks the database for events written by
+// other processes.  Events saved through the same store are delivered immediately.
+var PollInterval = time.Second
+
+// EventFilter restricts which events are returned.  Empty fields match everything, and
+// the time and sequence ranges are inclusive.
+type EventFilter struct {
+	EventTypes     []string
+	AggregateTypes []string
+	AggregateIDs   []uuid.UUID
+
+	Since time.Time
+	Until time.Time
+
+	MinSequence *int
+	MaxSequence *int
+}
+
+func (f EventFilter) Matches(e EventDescriptor) bool {
+	if len(f.EventTypes) > 0 && !slices.Contains(f.EventTypes, e.EventType) {
+		return false
+	}
+
+	if len(f.AggregateTypes) > 0 && !slices.Contains(f.AggregateTypes, e.AggregateType) {
+		return false
+	}
+
+	if len(f.AggregateIDs) > 0 && !slices.Contains(f.AggregateIDs, e.AggregateID) {
+		return false
+	}
+
+	if !f.Since.IsZero() && e.Timestamp.Before(f.Since) {
+		return false
+	}
+
+	if !f.Until.IsZero() && e.Timestamp.After(f.Until) {
+		return false
+	}
+
+	if f.MinSequence != nil && e.Sequence < *f.MinSequence {
+		return false
+	}
+
+	if f.MaxSequence != nil && e.Sequence > *f.MaxSequence {
+		return false
+	}
+
+	return true
+}
+
+// where builds the sql conditions for the filter, to be appended to a query which
+// already has a where clause.
+func (f EventFilter) where() (string, []any) {
+	sb := strings.Builder{}
+	args := []any{}
+
+	if len(f.EventTypes) > 0 {
+		names := make([]string, len(f.EventTypes))
+		for i, eventType := range f.EventTypes {
+			names[i] = fmt.Sprintf("@event_type_%d", i)
+			args = append(args, sql.Named(fmt.Sprintf("event_type_%d", i), eventType))
+		}
+		sb.WriteString(" and event_type in (" + strings.Join(names, ", ") + ")")
+	}
+
+	if len(f.AggregateTypes) > 0 {
+		names := make([]string, len(f.AggregateTypes))
+		for i, aggregateType := range f.AggregateTypes {
+			names[i] = fmt.Sprintf("@aggregate_type_%d", i)
+			args = append(args, sql.Named(fmt.Sprintf("aggregate_type_%d", i), aggregateType))
+		}
+		sb.WriteString(" and aggregate_type in (" + strings.Join(names, ", ") + ")")
+	}
+
+	if len(f.AggregateIDs) > 0 {
+		names := make([]string, len(f.AggregateIDs))
+		for i, id := range f.AggregateIDs {
+			names[i] = fmt.Sprintf("@aggregate_id_%d", i)
+			args = append(args, sql.Named(fmt.Sprintf("aggregate_id_%d", i), id.String()))
+		}
+		sb.WriteString(" and aggregate_id in (" + strings.Join(names, ", ") + ")")
+	}
+
+	// timestamps are stored in utc, so the text comparison sqlite does is in time order
+	if !f.Since.IsZero() {
+		sb.WriteString(" and timestamp >= @since")
+		args = append(args, sql.Named("since", f.Since.UTC()))
+	}
+
+	if !f.Until.IsZero() {
+		sb.WriteString(" and timestamp <= @until")
+		args = append(args, sql.Named("until", f.Until.UTC()))
+	}
+
+	if f.MinSequence != nil {
+		sb.WriteString(" and sequence >= @min_sequence")
+		args = append(args, sql.Named("min_sequence", *f.MinSequence))
+	}
+
+	if f.MaxSequence != nil {
+		sb.WriteString(" and sequence <= @max_sequence")
+		args = append(args, sql.Named("max_sequence", *f.MaxSequence))
+	}
+
+	return sb.String(), args
+}
+
+// notifier wakes up subscriptions when a store saves new events
+type notifier struct {
+	lock    sync.Mutex
+	changed chan struct{}
+}
+
+func (n *notifier) wait() <-chan struct{} {
+	n.lock.Lock()
+	defer n.lock.Unlock()
+
+	if n.changed == nil {
+		n.changed = make(chan struct{})
+	}
+
+	return n.changed
+}
+
+func (n *notifier) notify() {
+	n.lock.Lock()
+	defer n.lock.Unlock()
+
+	if n.changed != nil {
+		close(n.changed)
+		n.changed = nil
+	}
+}
+
+// subscribe repeatedly reads batches of events after the last position seen, waiting
+// for a notification or the poll interval when there are none left.
+func subscribe(ctx context.Context, n *notifier, fromPosition int64, read func(position int64) iter.Seq2[EventDescriptor, error]) iter.Seq2[EventDescriptor, error] {
+	return func(yield func(EventDescriptor, error) bool) {
+		position := fromPosition
+
+		for {
+			// take the channel before reading, so a save between the read and the wait isn't missed
+			changed := n.wait()
+
+			// the batch is read fully before yielding, so the consumer can use the
+			// store without waiting for this query's connection
+			batch := []EventDescriptor{}
+			for event, err := range read(position) {
+				if err != nil {
+					yield(event, err)
+					return
+				}
+				batch = append(batch, event)
+			}
+
+			for _, event := range batch {
+				position = event.Position
+				if !yield(event, nil) {
+					return
+				}
+			}
+
+			if len(batch) > 0 {
+				continue
+			}
+
+			select {
+			case <-ctx.Done():
+				return
+			case <-changed:
+			case <-time.After(PollInterval):
+			}
+		}
+	}
+}
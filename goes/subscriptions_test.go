@@ -0,0 +1,73 @@
+package goes
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/require"
+)
+
+func testSubscription(t *testing.T, store Store) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	watched := newCounter(uuid.New())
+	ignored := newCounter(uuid.New())
+
+	require.NoError(t, Apply(watched.state, counterIncremented{By: 1}))
+	require.NoError(t, Save(ctx, store, watched.state))
+	require.NoError(t, Apply(ignored.state, counterIncremented{By: 1}))
+	require.NoError(t, Save(ctx, store, ignored.state))
+
+	filter := EventFilter{AggregateIDs: []uuid.UUID{watched.state.ID()}}
+
+	received := []EventDescriptor{}
+	for event, err := range store.Subscribe(ctx, 0, filter) {
+		require.NoError(t, err)
+		received = append(received, event)
+
+		if len(received) == 1 {
+			// a live event, saved after the subscription has caught up
+			require.NoError(t, Apply(ignored.state, counterIncremented{By: 1}))
+			require.NoError(t, Save(ctx, store, ignored.state))
+			require.NoError(t, Apply(watched.state, counterIncremented{By: 2}))
+			require.NoError(t, Save(ctx, store, watched.state))
+		}
+
+		if len(received) == 2 {
+			break
+		}
+	}
+
+	require.Len(t, received, 2)
+	require.Equal(t, int64(1), received[0].Position)
+	require.Equal(t, int64(4), received[1].Position)
+	require.Equal(t, 1, received[1].Sequence)
+}
+
+func TestMemoryStoreSubscribe(t *testing.T) {
+	testSubscription(t, NewMemoryStore(nil))
+}
+
+func TestSqliteStoreSubscribe(t *testing.T) {
+	store := NewSqliteStore(newTestDatabase(t))
+	require.NoError(t, store.Initialise(context.Background()))
+
+	testSubscription(t, store)
+}
+
+func TestSubscriptionEndsWithContext(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	store := NewMemoryStore(nil)
+
+	go func() {
+		time.Sleep(10 * time.Millisecond)
+		cancel()
+	}()
+
+	for range store.Subscribe(ctx, 0, EventFilter{}) {
+		t.Fatal("no events should be received")
+	}
+}
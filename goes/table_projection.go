@@ -0,0 +1,376 @@
+package goes
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"strings"
+	"time"
+)
+
+// TableColumn describes how a field of a row struct is stored.  Columns are usually
+// built from `db` struct tags, in the form `db:"name,key"` or `db:"name,index"`.
+// Fields without a tag are not stored.
+type TableColumn struct {
+	Name  string
+	Type  string
+	Key   bool
+	Index bool
+
+	field int
+	json  bool
+}
+
+type TableSchema struct {
+	Name    string
+	Columns []TableColumn
+}
+
+// TableSchemaOf builds the schema for a row struct from its `db` tags.
+func TableSchemaOf[TRow any](name string) (TableSchema, error) {
+	rowType := reflect.TypeOf(*new(TRow))
+	if rowType.Kind() != reflect.Struct {
+		return TableSchema{}, fmt.Errorf("%s is not a struct", rowType.Name())
+	}
+
+	schema := TableSchema{Name: name}
+	keys := 0
+
+	for i := range rowType.NumField() {
+		field := rowType.Field(i)
+		tag, found := field.Tag.Lookup("db")
+		if !found || tag == "-" {
+			continue
+		}
+
+		parts := strings.Split(tag, ",")
+		column := TableColumn{
+			Name:  parts[0],
+			field: i,
+		}
+
+		for _, option := range parts[1:] {
+			switch option {
+			case "key":
+				column.Key = true
+				keys++
+			case "index":
+				column.Index = true
+			default:
+				return TableSchema{}, fmt.Errorf("unknown option '%s' on %s.%s", option, rowType.Name(), field.Name)
+			}
+		}
+
+		column.Type, column.json = columnType(field.Type)
+		schema.Columns = append(schema.Columns, column)
+	}
+
+	if keys != 1 {
+		return TableSchema{}, fmt.Errorf("%s must have exactly one key column, found %d", rowType.Name(), keys)
+	}
+
+	return schema, nil
+}
+
+// columnType returns the sqlite type for a field, and whether the value is stored as json
+func columnType(t reflect.Type) (string, bool) {
+	if t == reflect.TypeOf(time.Time{}) {
+		return "timestamp", false
+	}
+
+	switch t.Kind() {
+	case reflect.String:
+		return "text", false
+	case reflect.Bool, reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return "integer", false
+	case reflect.Float32, reflect.Float64:
+		return "real", false
+	}
+
+	return "text", true
+}
+
+func (s TableSchema) key() TableColumn {
+	for _, c := range s.Columns {
+		if c.Key {
+			return c
+		}
+	}
+	return TableColumn{}
+}
+
+// columns describes the table's columns for adding to a table created by an older version
+// of the schema, so they have defaults for the existing rows.
+func (s TableSchema) columns() []column {
+	columns := make([]column, 0, len(s.Columns))
+	for _, c := range s.Columns {
+		definition := c.Type
+		switch c.Type {
+		case "integer", "real":
+			definition += " not null default 0"
+		case "text":
+			definition += " not null default ''"
+		case "timestamp":
+			definition += " not null default '0001-01-01 00:00:00+00:00'"
+		}
+
+		columns = append(columns, column{name: c.Name, definition: definition})
+	}
+	return columns
+}
+
+func (s TableSchema) columnNames() string {
+	names := make([]string, len(s.Columns))
+	for i, c := range s.Columns {
+		names[i] = c.Name
+	}
+	return strings.Join(names, ", ")
+}
+
+// NewTableProjection creates a projection which stores one row per key in a table with a
+// column for each tagged field of TRow, rather than a single json document per aggregate.
+func NewTableProjection[TRow any](name string) (*TableProjection[TRow], error) {
+	schema, err := TableSchemaOf[TRow](name)
+	if err != nil {
+		return nil, err
+	}
+
+	return NewTableProjectionFromSchema[TRow](schema), nil
+}
+
+// NewTableProjectionFromSchema is for when the schema needs to differ from the one
+// generated by the struct tags, for example to add extra indexes.
+func NewTableProjectionFromSchema[TRow any](schema TableSchema) *TableProjection[TRow] {
+	key := schema.key()
+
+	columns := make([]string, len(schema.Columns))
+	values := make([]string, len(schema.Columns))
+	updates := make([]string, 0, len(schema.Columns))
+	for i, c := range schema.Columns {
+		definition := c.Name + " " + c.Type
+		if c.Key {
+			definition += " primary key"
+		}
+		columns[i] = definition
+		values[i] = "@" + c.Name
+
+		if !c.Key {
+			updates = append(updates, fmt.Sprintf("%s = @%s", c.Name, c.Name))
+		}
+	}
+
+	createTable := []string{
+		fmt.Sprintf("create table if not exists %s(\n\t%s\n)", schema.Name, strings.Join(columns, ",\n\t")),
+	}
+	for _, c := range schema.Columns {
+		if c.Index {
+			createTable = append(createTable, fmt.Sprintf(
+				"create index if not exists %s_%s on %s(%s)", schema.Name, c.Name, schema.Name, c.Name,
+			))
+		}
+	}
+
+	upsert := fmt.Sprintf(`
+		insert into
+			%s (%s)
+			values (%s)`, schema.Name, schema.columnNames(), strings.Join(values, ", "))
+	if len(updates) > 0 {
+		upsert += fmt.Sprintf(`
+		on conflict(%s) do update set
+			%s`, key.Name, strings.Join(updates, ",\n\t\t\t"))
+	} else {
+		upsert += fmt.Sprintf(`
+		on conflict(%s) do nothing`, key.Name)
+	}
+
+	return &TableProjection[TRow]{
+		schema:   schema,
+		handlers: map[string]func(ctx context.Context, table *TableProjection[TRow], event any) error{},
+
+		createTable: createTable,
+		readRow:     fmt.Sprintf(`select %s from %s where %s = @key`, schema.columnNames(), schema.Name, key.Name),
+		selectRows:  fmt.Sprintf(`select %s from %s`, schema.columnNames(), schema.Name),
+		upsertRow:   upsert,
+		deleteRow:   fmt.Sprintf(`delete from %s where %s = @key`, schema.Name, key.Name),
+		deleteAll:   fmt.Sprintf(`delete from %s`, schema.Name),
+	}
+}
+
+type TableProjection[TRow any] struct {
+	Tx       *sql.Tx
+	schema   TableSchema
+	handlers map[string]func(ctx context.Context, table *TableProjection[TRow], event any) error
+
+	createTable []string
+	readRow     string
+	selectRows  string
+	upsertRow   string
+	deleteRow   string
+	deleteAll   string
+}
+
+func AddTableHandler[TRow any, TEvent any](p *TableProjection[TRow], projector func(ctx context.Context, table *TableProjection[TRow], event TEvent) error) {
+	name := reflect.TypeOf(*new(TEvent)).Name()
+
+	p.handlers[name] = func(ctx context.Context, table *TableProjection[TRow], event any) error {
+
+		switch e := event.(type) {
+		case TEvent:
+			return projector(ctx, table, e)
+		case *TEvent:
+			return projector(ctx, table, *e)
+		default:
+			return fmt.Errorf("unable to handle %T", e)
+		}
+	}
+
+	// so that the event can be read even when no aggregate has registered it
+	registerEvent[TEvent]("", name, 1)
+}
+
+func (p *TableProjection[TRow]) Handles(eventType string) bool {
+	_, found := p.handlers[eventType]
+	return found
+}
+
+func (p *TableProjection[TRow]) Load(ctx context.Context, tx *sql.Tx) error {
+	p.Tx = tx
+
+	if _, err := tx.ExecContext(ctx, p.createTable[0]); err != nil {
+		return err
+	}
+
+	// a table made by an older TRow gains the new fields, which the rebuild for the
+	// projection's new version then fills in, before they can be indexed
+	if err := addMissingColumns(ctx, tx, p.schema.Name, p.schema.columns()); err != nil {
+		return err
+	}
+
+	for _, statement := range p.createTable[1:] {
+		if _, err := tx.ExecContext(ctx, statement); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (p *TableProjection[TRow]) Project(ctx context.Context, event EventDescriptor) error {
+	name := event.EventType
+	handler, found := p.handlers[name]
+	if !found {
+		return fmt.Errorf("no handler registered for %s", name)
+	}
+
+	return handler(ctx, p, event.Event)
+}
+
+func (p *TableProjection[TRow]) Save(ctx context.Context, tx *sql.Tx) error {
+	p.Tx = nil
+	return nil
+}
+
+func (p *TableProjection[TRow]) Wipe(ctx context.Context) error {
+	_, err := p.Tx.ExecContext(ctx, p.deleteAll)
+	return err
+}
+
+// Upsert inserts the row, or replaces the existing row with the same key.
+func (p *TableProjection[TRow]) Upsert(ctx context.Context, row TRow) error {
+	value := reflect.ValueOf(row)
+
+	args := make([]any, len(p.schema.Columns))
+	for i, c := range p.schema.Columns {
+		field := value.Field(c.field).Interface()
+
+		if c.json {
+			content, err := json.Marshal(field)
+			if err != nil {
+				return err
+			}
+			field = string(content)
+		}
+
+		args[i] = sql.Named(c.Name, field)
+	}
+
+	_, err := p.Tx.ExecContext(ctx, p.upsertRow, args...)
+	return err
+}
+
+func (p *TableProjection[TRow]) Delete(ctx context.Context, key any) error {
+	_, err := p.Tx.ExecContext(ctx, p.deleteRow, sql.Named("key", key))
+	return err
+}
+
+// Get reads the row with the given key from the current transaction, returning nil if
+// there is no such row.
+func (p *TableProjection[TRow]) Get(ctx context.Context, key any) (*TRow, error) {
+	rows, err := p.Tx.QueryContext(ctx, p.readRow, sql.Named("key", key))
+	if err != nil {
+		return nil, err
+	}
+
+	found, err := p.scanRows(rows)
+	if err != nil {
+		return nil, err
+	}
+	if len(found) == 0 {
+		return nil, nil
+	}
+
+	return found[0], nil
+}
+
+// Query reads rows outside of a projection, with the clause appended to the select
+// statement, e.g. `where state = @state order by added desc limit 10`.
+func (p *TableProjection[TRow]) Query(ctx context.Context, reader Queryable, clause string, args ...any) ([]*TRow, error) {
+	rows, err := reader.QueryContext(ctx, p.selectRows+" "+clause, args...)
+	if err != nil {
+		return nil, err
+	}
+
+	return p.scanRows(rows)
+}
+
+func (p *TableProjection[TRow]) scanRows(rows *sql.Rows) ([]*TRow, error) {
+	defer rows.Close()
+
+	results := []*TRow{}
+	for rows.Next() {
+		row := new(TRow)
+		value := reflect.ValueOf(row).Elem()
+
+		targets := make([]any, len(p.schema.Columns))
+		jsonColumns := map[int]*[]byte{}
+		for i, c := range p.schema.Columns {
+			if c.json {
+				content := []byte{}
+				jsonColumns[i] = &content
+				targets[i] = &content
+			} else {
+				targets[i] = value.Field(c.field).Addr().Interface()
+			}
+		}
+
+		if err := rows.Scan(targets...); err != nil {
+			return nil, err
+		}
+
+		for i, content := range jsonColumns {
+			if len(*content) == 0 {
+				continue
+			}
+			if err := json.Unmarshal(*content, value.Field(p.schema.Columns[i].field).Addr().Interface()); err != nil {
+				return nil, err
+			}
+		}
+
+		results = append(results, row)
+	}
+
+	return results, rows.Err()
+}
@@ -0,0 +1,132 @@
+package goes
+
+import (
+	"context"
+	"database/sql"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/require"
+)
+
+type counterRow struct {
+	ID      string    `db:"id,key"`
+	Total   int       `db:"total,index"`
+	Updated time.Time `db:"updated"`
+	Tags    []string  `db:"tags"`
+
+	Ignored string
+}
+
+func newCounterTable(t *testing.T) *TableProjection[counterRow] {
+	table, err := NewTableProjection[counterRow]("counters")
+	require.NoError(t, err)
+
+	AddTableHandler(table, func(ctx context.Context, table *TableProjection[counterRow], event counterIncremented) error {
+		return nil
+	})
+
+	return table
+}
+
+func TestTableSchemaFromTags(t *testing.T) {
+	schema, err := TableSchemaOf[counterRow]("counters")
+	require.NoError(t, err)
+
+	require.Len(t, schema.Columns, 4)
+	require.Equal(t, "id", schema.Columns[0].Name)
+	require.True(t, schema.Columns[0].Key)
+	require.Equal(t, "integer", schema.Columns[1].Type)
+	require.True(t, schema.Columns[1].Index)
+	require.Equal(t, "timestamp", schema.Columns[2].Type)
+	require.Equal(t, "text", schema.Columns[3].Type)
+}
+
+func TestTableSchemaNeedsAKey(t *testing.T) {
+	type noKey struct {
+		Name string `db:"name"`
+	}
+
+	_, err := TableSchemaOf[noKey]("no_key")
+	require.Error(t, err)
+}
+
+func TestTableProjectionRows(t *testing.T) {
+	ctx := context.Background()
+	db := newTestDatabase(t)
+	table := newCounterTable(t)
+
+	now := time.Now().UTC().Truncate(time.Second)
+
+	tx, err := db.BeginTx(ctx, nil)
+	require.NoError(t, err)
+	require.NoError(t, table.Load(ctx, tx))
+
+	require.NoError(t, table.Upsert(ctx, counterRow{ID: "one", Total: 1, Updated: now, Tags: []string{"a"}}))
+	require.NoError(t, table.Upsert(ctx, counterRow{ID: "two", Total: 2, Updated: now}))
+	require.NoError(t, table.Upsert(ctx, counterRow{ID: "one", Total: 3, Updated: now, Tags: []string{"a", "b"}, Ignored: "value"}))
+
+	row, err := table.Get(ctx, "one")
+	require.NoError(t, err)
+	require.Equal(t, &counterRow{ID: "one", Total: 3, Updated: now, Tags: []string{"a", "b"}}, row)
+
+	require.NoError(t, table.Delete(ctx, "two"))
+	missing, err := table.Get(ctx, "two")
+	require.NoError(t, err)
+	require.Nil(t, missing)
+
+	require.NoError(t, table.Save(ctx, tx))
+	require.NoError(t, tx.Commit())
+
+	rows, err := table.Query(ctx, db, "where total > @total", sql.Named("total", 2))
+	require.NoError(t, err)
+	require.Len(t, rows, 1)
+	require.Equal(t, "one", rows[0].ID)
+}
+
+func TestTableProjectionInStore(t *testing.T) {
+	ctx := context.Background()
+	db := newTestDatabase(t)
+	store := NewSqliteStore(db)
+
+	table, err := NewTableProjection[counterRow]("counter_totals")
+	require.NoError(t, err)
+	AddTableHandler(table, func(ctx context.Context, table *TableProjection[counterRow], event counterIncremented) error {
+		return table.Upsert(ctx, counterRow{ID: "total", Total: event.By})
+	})
+
+	require.NoError(t, store.RegisterProjection("counter_totals", table))
+	require.NoError(t, store.Initialise(ctx))
+
+	c := newCounter(uuid.New())
+	require.NoError(t, Apply(c.state, counterIncremented{By: 5}))
+	require.NoError(t, Save(ctx, store, c.state))
+
+	rows, err := table.Query(ctx, db, "")
+	require.NoError(t, err)
+	require.Len(t, rows, 1)
+	require.Equal(t, 5, rows[0].Total)
+}
+
+func TestTableProjectionAddsNewColumns(t *testing.T) {
+	ctx := context.Background()
+	db := newTestDatabase(t)
+
+	_, err := db.ExecContext(ctx, `create table counters (id text primary key, total integer not null)`)
+	require.NoError(t, err)
+	_, err = db.ExecContext(ctx, `insert into counters (id, total) values ('one', 1)`)
+	require.NoError(t, err)
+
+	table := newCounterTable(t)
+	tx, err := db.BeginTx(ctx, nil)
+	require.NoError(t, err)
+	require.NoError(t, table.Load(ctx, tx))
+	require.NoError(t, tx.Commit())
+
+	rows, err := table.Query(ctx, db, "")
+	require.NoError(t, err)
+	require.Len(t, rows, 1)
+	require.Equal(t, 1, rows[0].Total)
+	require.Nil(t, rows[0].Tags)
+}
@@ -0,0 +1,53 @@
+package goes
+
+import (
+	"encoding/json"
+	"fmt"
+)
+
+// RawEvent is the serialised form of an event, as it was stored.
+type RawEvent struct {
+	EventType string
+	Version   int
+	Data      json.RawMessage
+}
+
+// An Upcaster converts a stored event into a newer version, or into a different
+// event type when an event has been renamed.  The returned event must have a
+// different type or a higher version than the one passed in.
+type Upcaster func(event RawEvent) (RawEvent, error)
+
+var upcasters = map[string]map[int]Upcaster{}
+
+// RegisterUpcaster adds an upcaster for events of eventType which were stored with the given version.
+// Upcasters are chained, so an event stored as version 1 will be passed through the version 1
+// upcaster, then the version 2 upcaster and so on until there are no more upcasters to run.
+func RegisterUpcaster(eventType string, version int, upcaster Upcaster) {
+	versions, found := upcasters[eventType]
+	if !found {
+		versions = map[int]Upcaster{}
+		upcasters[eventType] = versions
+	}
+
+	versions[version] = upcaster
+}
+
+func upcast(event RawEvent) (RawEvent, error) {
+	for {
+		upcaster, found := upcasters[event.EventType][event.Version]
+		if !found {
+			return event, nil
+		}
+
+		next, err := upcaster(event)
+		if err != nil {
+			return event, fmt.Errorf("upcasting %s v%d: %w", event.EventType, event.Version, err)
+		}
+
+		if next.EventType == event.EventType && next.Version <= event.Version {
+			return event, fmt.Errorf("upcasting %s v%d did not change the event's type or increase its version", event.EventType, event.Version)
+		}
+
+		event = next
+	}
+}
@@ -0,0 +1,82 @@
+package goes
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/require"
+)
+
+type titleChanged struct {
+	Title string
+}
+
+type bookRenamed struct {
+	NewTitle string
+	Reason   string
+}
+
+func TestUpcastingRenamedEvents(t *testing.T) {
+	ctx := context.Background()
+	store := NewMemoryStore(nil)
+	id := uuid.New()
+
+	// an old event, saved before the rename
+	registerEvent[titleChanged]("", "titleChanged", 1)
+	_, err := store.Save(ctx, id, -1, []EventDescriptor{{
+		AggregateID: id,
+		Sequence:    0,
+		Timestamp:   time.Now(),
+		EventType:   "titleChanged",
+		Version:     1,
+		Event:       titleChanged{Title: "Old"},
+	}})
+	require.NoError(t, err)
+	delete(eventRegistry[""], "titleChanged")
+
+	RegisterUpcaster("titleChanged", 1, func(event RawEvent) (RawEvent, error) {
+		old := titleChanged{}
+		if err := json.Unmarshal(event.Data, &old); err != nil {
+			return event, err
+		}
+		data, err := json.Marshal(bookRenamed{NewTitle: old.Title})
+		return RawEvent{EventType: "bookRenamed", Version: 1, Data: data}, err
+	})
+	RegisterUpcaster("bookRenamed", 1, func(event RawEvent) (RawEvent, error) {
+		renamed := bookRenamed{}
+		if err := json.Unmarshal(event.Data, &renamed); err != nil {
+			return event, err
+		}
+		renamed.Reason = "unknown"
+		data, err := json.Marshal(renamed)
+		return RawEvent{EventType: "bookRenamed", Version: 2, Data: data}, err
+	})
+
+	state := NewAggregateState("book")
+	SetID(state, id)
+
+	var seen bookRenamed
+	Register(state, func(e bookRenamed) { seen = e }, EventVersion(2))
+
+	require.NoError(t, Load(ctx, store, state))
+	require.Equal(t, bookRenamed{NewTitle: "Old", Reason: "unknown"}, seen)
+}
+
+func TestUpcasterMustProgress(t *testing.T) {
+	RegisterUpcaster("stuckEvent", 1, func(event RawEvent) (RawEvent, error) {
+		return event, nil
+	})
+
+	_, err := upcast(RawEvent{EventType: "stuckEvent", Version: 1})
+	require.Error(t, err)
+}
+
+func TestNewerEventVersionsAreRejected(t *testing.T) {
+	registerEvent[titleChanged]("", "futureEvent", 1)
+
+	e := EventDescriptor{EventType: "futureEvent", Version: 3}
+	require.Error(t, e.decode([]byte(`{}`), nil))
+}
@@ -26,10 +26,37 @@ func main() {
 
 		"catalogue search": command.NewCommand(catalogue.NewSearchCommand()),
 
-		"library list": command.NewCommand(library.NewListCommand()),
-		"library add":  command.NewCommand(library.NewAddCommand()),
+		"library list":     command.NewCommand(library.NewListCommand()),
+		"library add":      command.NewCommand(library.NewAddCommand()),
+		"library start":    command.NewCommand(library.NewStartCommand()),
+		"library finish":   command.NewCommand(library.NewFinishCommand()),
+		"library progress": command.NewCommand(library.NewProgressCommand()),
+		"library rate":     command.NewCommand(library.NewRateCommand()),
+		"library remove":   command.NewCommand(library.NewRemoveCommand()),
 
-		"goes rebuild views": command.NewCommand(goes.NewGoesCommand()),
+		"library tags":        command.NewCommand(library.NewTagsListCommand()),
+		"library tags add":    command.NewCommand(library.NewTagsAddCommand()),
+		"library tags remove": command.NewCommand(library.NewTagsRemoveCommand()),
+		"library tags rename": command.NewCommand(library.NewTagsRenameCommand()),
+		"library tags merge":  command.NewCommand(library.NewTagsMergeCommand()),
+
+		"goes rebuild views":  command.NewCommand(goes.NewGoesCommand()),
+		"goes project":        command.NewCommand(goes.NewProjectCommand()),
+		"goes projections":    command.NewCommand(goes.NewProjectionsCommand()),
+		"goes export":         command.NewCommand(goes.NewExportCommand()),
+		"goes import":         command.NewCommand(goes.NewImportCommand()),
+		"goes events list":    command.NewCommand(goes.NewEventsListCommand()),
+		"goes events show":    command.NewCommand(goes.NewEventsShowCommand()),
+		"goes events tail":    command.NewCommand(goes.NewEventsTailCommand()),
+		"goes verify":         command.NewCommand(goes.NewVerifyCommand()),
+		"goes forget":         command.NewCommand(goes.NewForgetCommand()),
+		"goes failures":       command.NewCommand(goes.NewFailuresCommand()),
+		"goes failures retry": command.NewCommand(goes.NewRetryFailuresCommand()),
+		"goes deliveries":     command.NewCommand(goes.NewDeliveriesCommand()),
+	}
+
+	for name, factory := range commands {
+		commands[name] = command.Named(name, factory)
 	}
 
 	cli := &cli.CLI{
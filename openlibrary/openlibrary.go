@@ -24,6 +24,7 @@ type Book struct {
 	Subtitle string
 	Authors  []Author
 	Covers   []int // ??
+	Pages    int
 
 	PublishDate *time.Time
 
@@ -73,6 +74,23 @@ func FindBooksByIsbn(ctx context.Context, reader Readable, isbn string) ([]*Book
 	return books, nil
 }
 
+// PageCount returns the number of pages of the edition with the isbn, or 0 if the
+// catalogue doesn't have the edition or doesn't know its length.
+func PageCount(ctx context.Context, reader Readable, isbn string) (int, error) {
+	books, err := FindBooksByIsbn(ctx, reader, isbn)
+	if err != nil {
+		return 0, err
+	}
+
+	for _, book := range books {
+		if book.Pages > 0 {
+			return book.Pages, nil
+		}
+	}
+
+	return 0, nil
+}
+
 func FindBooks(ctx context.Context, reader Readable, search string) ([]*Book, error) {
 	ctx, span := tr.Start(ctx, "find_books")
 	defer span.End()
@@ -162,6 +180,7 @@ func buildResults(ctx context.Context, rows iter.Seq[bookResult]) ([]*Book, erro
 				Isbns:          append(editionDto.Isbn13, editionDto.Isbn10...),
 				Authors:        authors,
 				Covers:         editionDto.Covers,
+				Pages:          editionDto.NumberOfPages,
 				rank:           rank,
 				openLibraryKey: editionDto.Key,
 			}
@@ -236,7 +255,8 @@ type editionDto struct {
 	Subtitle       string
 	PhysicalFormat string `json:"physical_format"`
 
-	PublishDate string `json:"publish_date"`
+	PublishDate   string `json:"publish_date"`
+	NumberOfPages int    `json:"number_of_pages"`
 
 	Isbn10 []string `json:"isbn_10"`
 	Isbn13 []string `json:"isbn_13"`
@@ -0,0 +1,58 @@
+package openlibrary
+
+import (
+	"context"
+	"database/sql"
+	"kirjasto/tracing"
+)
+
+// CreateTables creates the catalogue's tables if they don't already exist.  They are
+// populated by the openlibrary import command.
+func CreateTables(ctx context.Context, writer *sql.DB) error {
+	ctx, span := tr.Start(ctx, "create_tables")
+	defer span.End()
+
+	statements := []string{
+		`create table if not exists editions (
+			id text primary key,
+			data blob
+		)`,
+		`create virtual table if not exists editions_fts using fts5 (
+			edition_id,
+			title,
+			subtitle
+		)`,
+		`create table if not exists editions_works_link (
+			edition_id text,
+			work_id text,
+			foreign key(edition_id) references editions(id)
+		)`,
+		`create table if not exists editions_isbns_link (
+			edition_id text,
+			isbn text,
+			foreign key(edition_id) references editions(id)
+		)`,
+		`create table if not exists authors (
+			id text primary key,
+			data blob
+		)`,
+		`create virtual table if not exists authors_fts using fts5 (
+			author_id,
+			name
+		)`,
+		`create table if not exists editions_authors_link (
+			edition_id text,
+			author_id text,
+			foreign key(edition_id) references editions(id),
+			foreign key(author_id) references authors(id)
+		)`,
+	}
+
+	for _, statement := range statements {
+		if _, err := writer.ExecContext(ctx, statement); err != nil {
+			return tracing.Error(span, err)
+		}
+	}
+
+	return nil
+}
@@ -4,6 +4,8 @@ import (
 	"fmt"
 	"html/template"
 	"strings"
+
+	"github.com/google/uuid"
 )
 
 var funcs = template.FuncMap(map[string]any{
@@ -20,6 +22,11 @@ var funcs = template.FuncMap(map[string]any{
 
 		return ""
 	},
+	// requestID is a new key for each rendered form, so that submitting it twice only
+	// saves once
+	"requestID": func() string {
+		return uuid.NewString()
+	},
 	"dict": func(values ...any) map[string]any {
 		dict := map[string]any{}
 		total := len(values)
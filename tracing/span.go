@@ -36,3 +36,12 @@ func HashedString(key string, value string) attribute.KeyValue {
 
 	return attribute.String(key, hex.EncodeToString(hash))
 }
+
+func TraceID(ctx context.Context) string {
+	spanContext := trace.SpanContextFromContext(ctx)
+	if !spanContext.HasTraceID() {
+		return ""
+	}
+
+	return spanContext.TraceID().String()
+}
@@ -5,6 +5,7 @@ import (
 	"embed"
 	"io/fs"
 	"kirjasto/config"
+	"kirjasto/goes"
 	"kirjasto/routing"
 	"kirjasto/template"
 	"kirjasto/tracing"
@@ -22,7 +23,7 @@ var tr = otel.Tracer("ui")
 //go:embed */*
 var staticFiles embed.FS
 
-func RegisterUI(ctx context.Context, cfg *config.Config, server *http.ServeMux) error {
+func RegisterUI(ctx context.Context, cfg *config.Config, server *http.ServeMux, store *goes.SqliteStore) error {
 	ctx, span := tr.Start(ctx, "register_handlers")
 	defer span.End()
 
@@ -61,8 +62,10 @@ func RegisterUI(ctx context.Context, cfg *config.Config, server *http.ServeMux)
 	handlers = append(handlers, StaticFilesHandler(fs))
 
 	// app areas
+	library := landing.Handlers(store)
 	handlers = append(handlers,
-		landing.RegisterHandlers,
+		library.Register,
+		library.RegisterBooks,
 		catalogue.RegisterHandlers,
 	)
 
@@ -0,0 +1,160 @@
+package landing
+
+import (
+	"context"
+	"kirjasto/config"
+	"kirjasto/domain"
+	"kirjasto/goes"
+	"kirjasto/routing"
+	"kirjasto/storage"
+	"kirjasto/template"
+	"kirjasto/tracing"
+	"net/http"
+	"net/url"
+	"time"
+)
+
+// ratings are the choices for a book's rating, in half stars
+var ratings = []float64{0, 0.5, 1, 1.5, 2, 2.5, 3, 3.5, 4, 4.5, 5}
+
+func (h *handlers) RegisterBooks(ctx context.Context, config *config.Config, mux *http.ServeMux, engine *template.TemplateEngine) error {
+
+	update := func(ctx context.Context, command func(library *domain.Library) error) error {
+		if err := domain.UpdateLibrary(ctx, h.store, domain.LibraryID, command); err != nil {
+			return err
+		}
+
+		// the library's view is async, and is caught up so that the page redirected to
+		// shows the change
+		_, err := h.store.CatchUp(ctx, goes.DefaultBatchSize)
+		return err
+	}
+
+	mux.HandleFunc("GET /books/{key}", routing.RouteHandler(func(w http.ResponseWriter, r *http.Request) error {
+		ctx, span := tr.Start(r.Context(), "get_book")
+		defer span.End()
+
+		book, err := libraryEntry(ctx, config, r.PathValue("key"))
+		if err != nil {
+			return tracing.Error(span, err)
+		}
+
+		dto := map[string]any{
+			"Book":    book,
+			"Ratings": ratings,
+		}
+
+		w.Header().Set("Content-Type", "text/html")
+		if err := engine.Render(r.Context(), "landing/book.html", dto, w); err != nil {
+			return tracing.Error(span, err)
+		}
+		return nil
+	}))
+
+	mux.HandleFunc("POST /books/{key}/rating", routing.RouteHandler(func(w http.ResponseWriter, r *http.Request) error {
+		ctx, span := tr.Start(r.Context(), "post_rating")
+		defer span.End()
+
+		key := r.PathValue("key")
+
+		rating, err := domain.ParseRating(r.PostFormValue("rating"))
+		if err != nil {
+			return tracing.Error(span, err)
+		}
+
+		err = update(ctx, func(library *domain.Library) error {
+			return library.RateBook(key, rating, time.Now())
+		})
+		if err != nil {
+			return tracing.Error(span, err)
+		}
+
+		http.Redirect(w, r, "/books/"+url.PathEscape(key), http.StatusSeeOther)
+		return nil
+	}))
+
+	mux.HandleFunc("POST /books/{key}/review", routing.RouteHandler(func(w http.ResponseWriter, r *http.Request) error {
+		ctx, span := tr.Start(r.Context(), "post_review")
+		defer span.End()
+
+		key := r.PathValue("key")
+
+		err := update(ctx, func(library *domain.Library) error {
+			if r.PostFormValue("action") == "delete" {
+				return library.DeleteReview(key, time.Now())
+			}
+			return library.ReviewBook(key, r.PostFormValue("review"), time.Now())
+		})
+		if err != nil {
+			return tracing.Error(span, err)
+		}
+
+		http.Redirect(w, r, "/books/"+url.PathEscape(key), http.StatusSeeOther)
+		return nil
+	}))
+
+	mux.HandleFunc("GET /books/{key}/remove", routing.RouteHandler(func(w http.ResponseWriter, r *http.Request) error {
+		ctx, span := tr.Start(r.Context(), "get_remove_book")
+		defer span.End()
+
+		book, err := libraryEntry(ctx, config, r.PathValue("key"))
+		if err != nil {
+			return tracing.Error(span, err)
+		}
+
+		dto := map[string]any{
+			"Book":    book,
+			"Reasons": domain.RemovalReasons,
+			"Today":   time.Now().Format(time.DateOnly),
+		}
+
+		w.Header().Set("Content-Type", "text/html")
+		if err := engine.Render(r.Context(), "landing/remove.html", dto, w); err != nil {
+			return tracing.Error(span, err)
+		}
+		return nil
+	}))
+
+	mux.HandleFunc("POST /books/{key}/remove", routing.RouteHandler(func(w http.ResponseWriter, r *http.Request) error {
+		ctx, span := tr.Start(r.Context(), "post_remove_book")
+		defer span.End()
+
+		key := r.PathValue("key")
+
+		when := time.Now()
+		if date := r.PostFormValue("when"); date != "" {
+			parsed, err := time.ParseInLocation(time.DateOnly, date, time.Local)
+			if err != nil {
+				return tracing.Error(span, err)
+			}
+			when = parsed
+		}
+
+		err := update(ctx, func(library *domain.Library) error {
+			return library.RemoveBook(key, r.PostFormValue("reason"), when)
+		})
+		if err != nil {
+			return tracing.Error(span, err)
+		}
+
+		http.Redirect(w, r, "/", http.StatusSeeOther)
+		return nil
+	}))
+
+	return nil
+}
+
+// libraryEntry reads a book from the stored view of the library
+func libraryEntry(ctx context.Context, config *config.Config, key string) (*domain.LibraryEntry, error) {
+	reader, err := storage.Reader(ctx, config.DatabaseFile)
+	if err != nil {
+		return nil, err
+	}
+
+	library, err := domain.NewLibraryProjection().View(ctx, reader, domain.LibraryID)
+	if err != nil {
+		return nil, err
+	}
+
+	return library.Entry(key)
+}
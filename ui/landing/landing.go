@@ -2,9 +2,11 @@ package landing
 
 import (
 	"context"
+	"database/sql"
 	"fmt"
 	"kirjasto/config"
 	"kirjasto/domain"
+	"kirjasto/goes"
 	"kirjasto/routing"
 	"kirjasto/storage"
 	"kirjasto/template"
@@ -16,7 +18,19 @@ import (
 
 var tr = otel.Tracer("ui.landing")
 
-func RegisterHandlers(ctx context.Context, config *config.Config, mux *http.ServeMux, engine *template.TemplateEngine) error {
+// Handlers creates the library's handlers, which change and view the library using the
+// server's event store.
+func Handlers(store *goes.SqliteStore) *handlers {
+	return &handlers{
+		store: store,
+	}
+}
+
+type handlers struct {
+	store *goes.SqliteStore
+}
+
+func (h *handlers) Register(ctx context.Context, config *config.Config, mux *http.ServeMux, engine *template.TemplateEngine) error {
 	mux.HandleFunc("GET /", routing.RouteHandler(func(w http.ResponseWriter, r *http.Request) error {
 		ctx, span := tr.Start(r.Context(), "get_landing")
 		defer span.End()
@@ -30,6 +44,8 @@ func RegisterHandlers(ctx context.Context, config *config.Config, mux *http.Serv
 			Type:      r.FormValue("type"),
 			Ownership: r.FormValue("ownership"),
 			Progress:  r.FormValue("progress"),
+			Tag:       r.FormValue("tag"),
+			AsOf:      r.FormValue("as_of"),
 		}
 
 		reader, err := storage.Reader(ctx, config.DatabaseFile)
@@ -37,8 +53,7 @@ func RegisterHandlers(ctx context.Context, config *config.Config, mux *http.Serv
 			return tracing.Error(span, err)
 		}
 
-		p := domain.NewLibraryProjection()
-		library, err := p.View(ctx, reader, domain.LibraryID)
+		library, err := h.libraryView(ctx, reader, filter.AsOf)
 		if err != nil {
 			return tracing.Error(span, err)
 		}
@@ -46,6 +61,8 @@ func RegisterHandlers(ctx context.Context, config *config.Config, mux *http.Serv
 		dto := map[string]any{
 			"Filter":  filter,
 			"Library": library,
+			"Books":   domain.TaggedWith(library.BooksInState(filter.Progress), filter.Tag),
+			"Tags":    library.Tags(),
 		}
 
 		fmt.Println(filter.Filter, filter.Ownership, filter.Progress, filter.Type)
@@ -64,4 +81,34 @@ type FilterOptions struct {
 	Type      string
 	Ownership string
 	Progress  string
+	Tag       string
+	AsOf      string
+}
+
+// libraryView reads the stored view, or builds it from the events when looking at the
+// library as it was in the past.
+func (h *handlers) libraryView(ctx context.Context, reader *sql.DB, asOf string) (*domain.LibraryView, error) {
+	p := domain.NewLibraryProjection()
+
+	if asOf == "" {
+		view, err := p.View(ctx, reader, domain.LibraryID)
+		if err == sql.ErrNoRows {
+			// the projector hasn't caught up with the library's first events yet
+			return &domain.LibraryView{}, nil
+		}
+		return view, err
+	}
+
+	point, err := goes.ParsePointInTime(asOf)
+	if err != nil {
+		return nil, err
+	}
+
+	view, err := p.ViewAt(ctx, reader, h.store, domain.LibraryID, point)
+	if err == goes.ErrNotFound {
+		// the library didn't exist yet
+		return &domain.LibraryView{}, nil
+	}
+
+	return view, err
 }
@@ -0,0 +1,251 @@
+// Package webhook sends the events queued in the event store's outbox to webhook urls,
+// retrying failed deliveries with exponential backoff.
+package webhook
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"kirjasto/goes"
+	"kirjasto/tracing"
+	"net/http"
+	"strconv"
+	"time"
+
+	"go.opentelemetry.io/contrib/instrumentation/net/http/otelhttp"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+)
+
+var tr = otel.Tracer("webhook")
+
+const (
+	SignatureHeader = "X-Kirjasto-Signature"
+	EventHeader     = "X-Kirjasto-Event"
+	DeliveryHeader  = "X-Kirjasto-Delivery"
+)
+
+const (
+	DefaultMaxAttempts = 8
+	DefaultBackoff     = 10 * time.Second
+	DefaultMaxBackoff  = time.Hour
+	DefaultBatchSize   = 50
+)
+
+// Payload is the json body posted to the webhook.  The delivery id is the same for every
+// attempt at sending the message, so receivers can ignore repeats.  The event's personal
+// fields are sent as goes.Redacted.
+type Payload struct {
+	DeliveryID int64 `json:"delivery_id"`
+	EventID    int64 `json:"event_id"`
+	goes.ExportedEvent
+}
+
+type Option func(d *Deliverer)
+
+// WithClient sets the http client used to send messages.
+func WithClient(client *http.Client) Option {
+	return func(d *Deliverer) {
+		d.client = client
+	}
+}
+
+// WithBackoff sets the wait before the first retry, which doubles for each retry after
+// it, up to the maximum.
+func WithBackoff(initial time.Duration, maximum time.Duration) Option {
+	return func(d *Deliverer) {
+		d.backoff = initial
+		d.maxBackoff = maximum
+	}
+}
+
+// WithMaxAttempts sets how many times a message is sent before giving up on it.
+func WithMaxAttempts(attempts int) Option {
+	return func(d *Deliverer) {
+		d.maxAttempts = attempts
+	}
+}
+
+// NewDeliverer creates a deliverer for the store's outbox, which signs each message with
+// the secret.  The outbox destinations are the webhook urls, see goes.WithOutbox.
+func NewDeliverer(store *goes.SqliteStore, secret string, options ...Option) *Deliverer {
+	d := &Deliverer{
+		store: store,
+		client: &http.Client{
+			Timeout:   10 * time.Second,
+			Transport: otelhttp.NewTransport(http.DefaultTransport),
+		},
+		secret:      []byte(secret),
+		maxAttempts: DefaultMaxAttempts,
+		backoff:     DefaultBackoff,
+		maxBackoff:  DefaultMaxBackoff,
+	}
+
+	for _, option := range options {
+		option(d)
+	}
+
+	return d
+}
+
+type Deliverer struct {
+	store  *goes.SqliteStore
+	client *http.Client
+	secret []byte
+
+	maxAttempts int
+	backoff     time.Duration
+	maxBackoff  time.Duration
+}
+
+// Run sends due messages every interval, until the context is cancelled.
+func (d *Deliverer) Run(ctx context.Context, interval time.Duration) error {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		if _, err := d.DeliverDue(ctx); err != nil {
+			return err
+		}
+
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+		}
+	}
+}
+
+// DeliverDue sends the messages whose next attempt is due, returning how many were
+// delivered.  A failed delivery is recorded in the delivery log and retried later, rather
+// than returned as an error.
+func (d *Deliverer) DeliverDue(ctx context.Context) (int, error) {
+	ctx, span := tr.Start(ctx, "deliver_due")
+	defer span.End()
+
+	messages, err := d.store.DueOutbox(ctx, DefaultBatchSize)
+	if err != nil {
+		return 0, tracing.Error(span, err)
+	}
+
+	delivered := 0
+	for _, message := range messages {
+		detail, sendErr := d.send(ctx, message)
+		if sendErr == nil {
+			if err := d.store.RecordDelivery(ctx, message.ID, detail); err != nil {
+				return delivered, tracing.Error(span, err)
+			}
+			delivered++
+			continue
+		}
+
+		retryAt := time.Time{}
+		if attempts := message.Attempts + 1; attempts < d.maxAttempts {
+			retryAt = time.Now().Add(d.retryDelay(attempts))
+		}
+
+		if err := d.store.RecordFailedDelivery(ctx, message.ID, sendErr.Error(), retryAt); err != nil {
+			return delivered, tracing.Error(span, err)
+		}
+	}
+
+	span.SetAttributes(
+		attribute.Int("webhook.due", len(messages)),
+		attribute.Int("webhook.delivered", delivered),
+	)
+
+	return delivered, nil
+}
+
+// retryDelay is the wait after the given number of failed attempts
+func (d *Deliverer) retryDelay(attempts int) time.Duration {
+	delay := d.backoff
+	for i := 1; i < attempts && delay < d.maxBackoff; i++ {
+		delay *= 2
+	}
+
+	return min(delay, d.maxBackoff)
+}
+
+func (d *Deliverer) send(ctx context.Context, message goes.OutboxMessage) (string, error) {
+	ctx, span := tr.Start(ctx, "send")
+	defer span.End()
+
+	span.SetAttributes(
+		attribute.String("webhook.url", message.Destination),
+		attribute.Int64("webhook.delivery_id", message.ID),
+		attribute.Int("webhook.attempt", message.Attempts+1),
+	)
+
+	body, err := d.payload(message)
+	if err != nil {
+		return "", tracing.Error(span, err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, message.Destination, bytes.NewReader(body))
+	if err != nil {
+		return "", tracing.Error(span, err)
+	}
+
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(EventHeader, message.Event.EventType)
+	req.Header.Set(DeliveryHeader, strconv.FormatInt(message.ID, 10))
+	req.Header.Set(SignatureHeader, Sign(d.secret, body))
+
+	res, err := d.client.Do(req)
+	if err != nil {
+		return "", tracing.Error(span, err)
+	}
+	defer res.Body.Close()
+	io.Copy(io.Discard, res.Body)
+
+	span.SetAttributes(attribute.Int("http.status_code", res.StatusCode))
+	if res.StatusCode < 200 || res.StatusCode > 299 {
+		return "", tracing.Errorf(span, "%s responded %s", message.Destination, res.Status)
+	}
+
+	return res.Status, nil
+}
+
+func (d *Deliverer) payload(message goes.OutboxMessage) ([]byte, error) {
+	event := message.Event
+
+	data, err := event.MarshalRedacted()
+	if err != nil {
+		return nil, err
+	}
+
+	return json.Marshal(Payload{
+		DeliveryID: message.ID,
+		EventID:    event.Position,
+		ExportedEvent: goes.ExportedEvent{
+			AggregateID:   event.AggregateID,
+			AggregateType: event.AggregateType,
+			Sequence:      event.Sequence,
+			Timestamp:     event.Timestamp,
+			EventType:     event.EventType,
+			Version:       event.Version,
+			Data:          data,
+			Metadata:      event.Metadata,
+		},
+	})
+}
+
+// Sign is the value of the signature header for the body: the hex encoded HMAC-SHA256
+// of the body, keyed with the secret.
+func Sign(secret []byte, body []byte) string {
+	mac := hmac.New(sha256.New, secret)
+	mac.Write(body)
+
+	return fmt.Sprintf("sha256=%s", hex.EncodeToString(mac.Sum(nil)))
+}
+
+// Verify checks a signature made by Sign, for receivers of the webhook.
+func Verify(secret []byte, body []byte, signature string) bool {
+	return hmac.Equal([]byte(Sign(secret, body)), []byte(signature))
+}
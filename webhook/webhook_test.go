@@ -0,0 +1,149 @@
+package webhook
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"kirjasto/goes"
+	"kirjasto/goes/goestest"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/require"
+)
+
+type bookShelved struct {
+	Title     string
+	ShelvedBy string `goes:"personal"`
+}
+
+// receiver records the webhooks it is sent, failing the first `failures` requests
+type receiver struct {
+	lock     sync.Mutex
+	failures int
+	payloads []Payload
+}
+
+func (r *receiver) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	body, _ := io.ReadAll(req.Body)
+	if !Verify([]byte("secret"), body, req.Header.Get(SignatureHeader)) {
+		w.WriteHeader(http.StatusUnauthorized)
+		return
+	}
+
+	if r.failures > 0 {
+		r.failures--
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	payload := Payload{}
+	json.Unmarshal(body, &payload)
+	r.payloads = append(r.payloads, payload)
+}
+
+func saveShelved(t *testing.T, url string, event bookShelved) *goes.SqliteStore {
+	ctx := context.Background()
+	store := goes.NewSqliteStore(goestest.NewDatabase(t), goes.WithOutbox(url, "bookShelved"))
+	require.NoError(t, store.Initialise(ctx))
+
+	state := goes.NewAggregateState("shelf")
+	goes.SetID(state, uuid.New())
+	goes.Register(state, func(e bookShelved) {})
+
+	require.NoError(t, goes.Apply(state, event))
+	require.NoError(t, goes.Save(ctx, store, state))
+
+	return store
+}
+
+func TestDeliveryWithRetries(t *testing.T) {
+	ctx := context.Background()
+	hook := &receiver{failures: 2}
+	server := httptest.NewServer(hook)
+	defer server.Close()
+
+	store := saveShelved(t, server.URL, bookShelved{Title: "Dune"})
+	deliverer := NewDeliverer(store, "secret", WithBackoff(0, 0))
+
+	for range 2 {
+		delivered, err := deliverer.DeliverDue(ctx)
+		require.NoError(t, err)
+		require.Equal(t, 0, delivered)
+	}
+
+	delivered, err := deliverer.DeliverDue(ctx)
+	require.NoError(t, err)
+	require.Equal(t, 1, delivered)
+
+	require.Len(t, hook.payloads, 1)
+	require.Equal(t, "bookShelved", hook.payloads[0].EventType)
+	require.Equal(t, "shelf", hook.payloads[0].AggregateType)
+	require.JSONEq(t, `{"Title":"Dune","ShelvedBy":"[redacted]"}`, string(hook.payloads[0].Data))
+
+	log, err := store.DeliveryLog(ctx, 10)
+	require.NoError(t, err)
+	require.Len(t, log, 3)
+	require.True(t, log[0].Succeeded)
+	require.Equal(t, "200 OK", log[0].Detail)
+
+	// nothing is sent again once delivered
+	delivered, err = deliverer.DeliverDue(ctx)
+	require.NoError(t, err)
+	require.Equal(t, 0, delivered)
+}
+
+func TestPersonalFieldsAreRedacted(t *testing.T) {
+	ctx := context.Background()
+	hook := &receiver{}
+	server := httptest.NewServer(hook)
+	defer server.Close()
+
+	store := saveShelved(t, server.URL, bookShelved{Title: "Dune", ShelvedBy: "alice"})
+	deliverer := NewDeliverer(store, "secret", WithBackoff(0, 0))
+
+	delivered, err := deliverer.DeliverDue(ctx)
+	require.NoError(t, err)
+	require.Equal(t, 1, delivered)
+
+	shelved := bookShelved{}
+	require.NoError(t, json.Unmarshal(hook.payloads[0].Data, &shelved))
+	require.Equal(t, bookShelved{Title: "Dune", ShelvedBy: goes.Redacted}, shelved)
+}
+
+func TestDeliveryIsAbandonedAfterMaxAttempts(t *testing.T) {
+	ctx := context.Background()
+	hook := &receiver{failures: 10}
+	server := httptest.NewServer(hook)
+	defer server.Close()
+
+	store := saveShelved(t, server.URL, bookShelved{Title: "Dune"})
+	deliverer := NewDeliverer(store, "secret", WithBackoff(0, 0), WithMaxAttempts(3))
+
+	for range 5 {
+		_, err := deliverer.DeliverDue(ctx)
+		require.NoError(t, err)
+	}
+
+	require.Equal(t, 7, hook.failures)
+
+	log, err := store.DeliveryLog(ctx, 10)
+	require.NoError(t, err)
+	require.Len(t, log, 3)
+}
+
+func TestRetryDelayDoubles(t *testing.T) {
+	deliverer := NewDeliverer(nil, "", WithBackoff(time.Second, 5*time.Second))
+
+	require.Equal(t, time.Second, deliverer.retryDelay(1))
+	require.Equal(t, 2*time.Second, deliverer.retryDelay(2))
+	require.Equal(t, 4*time.Second, deliverer.retryDelay(3))
+	require.Equal(t, 5*time.Second, deliverer.retryDelay(4))
+}